@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lgug2z/sgrab/sonarr"
+)
+
+// SeriesTypeDaily is the Sonarr series type of shows, such as talk shows,
+// whose episodes are known by air date rather than by number.
+const SeriesTypeDaily = "daily"
+
+const airDateFormat = "2006-01-02"
+
+var airDateRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+func isAirDate(s string) bool {
+	return airDateRegex.MatchString(s)
+}
+
+// parseDateRange parses a --date-range in the format "2024-03-01..2024-03-15".
+// Either end may be left out to leave the range open on that side.
+func parseDateRange(spec string) (from, to string, err error) {
+	bounds := strings.SplitN(spec, "..", 2)
+	if len(bounds) != 2 {
+		return "", "", ErrInvalidDateRange(spec)
+	}
+
+	for _, bound := range bounds {
+		if len(bound) == 0 {
+			continue
+		}
+
+		if _, err := time.Parse(airDateFormat, bound); err != nil || !isAirDate(bound) {
+			return "", "", ErrInvalidDateRange(spec)
+		}
+	}
+
+	return bounds[0], bounds[1], nil
+}
+
+// findEpisodeFileIDsByAirDate returns the file IDs of the episodes that aired
+// between from and to inclusive, in the order they aired. An empty from or to
+// leaves the range open on that side. Episodes without a file are skipped.
+func findEpisodeFileIDsByAirDate(episodes []sonarr.Episode, from, to string) []int {
+	sorted := sortByAirDate(episodes)
+
+	var ids []int
+	for _, e := range sorted {
+		if e.EpisodeFileID == 0 || len(e.AirDate) == 0 {
+			continue
+		}
+
+		// Air dates are formatted so that they sort as strings
+		if (len(from) == 0 || e.AirDate >= from) && (len(to) == 0 || e.AirDate <= to) {
+			ids = append(ids, e.EpisodeFileID)
+		}
+	}
+
+	return ids
+}
+
+// findLatestEpisodeFileIDs returns the file IDs of the n episodes with a file
+// that aired most recently, in the order they aired.
+func findLatestEpisodeFileIDs(episodes []sonarr.Episode, n int) []int {
+	var ids []int
+	for _, e := range sortByAirDate(episodes) {
+		if e.EpisodeFileID != 0 && len(e.AirDate) > 0 {
+			ids = append(ids, e.EpisodeFileID)
+		}
+	}
+
+	ids = uniqueIDs(ids)
+	if len(ids) > n {
+		ids = ids[len(ids)-n:]
+	}
+
+	return ids
+}
+
+func sortByAirDate(episodes []sonarr.Episode) []sonarr.Episode {
+	sorted := make([]sonarr.Episode, len(episodes))
+	copy(sorted, episodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].AirDateUtc.Equal(sorted[j].AirDateUtc) {
+			return sorted[i].AirDateUtc.Before(sorted[j].AirDateUtc)
+		}
+		return sorted[i].AirDate < sorted[j].AirDate
+	})
+
+	return sorted
+}
+
+// selectEpisodeFileIDs returns the file IDs of the episodes of series chosen
+// with --episode, --absolute, --date-range or --latest. Episode numbers are
+// scene numbers if the series uses scene numbering. If any requested episode
+// has no file on the seedbox, nothing is selected; for a daily series asked
+// for by episode number the error suggests using air dates.
+func selectEpisodeFileIDs(f Flags, series sonarr.Series, episodes []sonarr.Episode) ([]int, error) {
+	selectors := 0
+	for _, set := range []bool{len(f.Episode) > 0, len(f.Absolute) > 0, len(f.DateRange) > 0, f.Latest > 0} {
+		if set {
+			selectors++
+		}
+	}
+
+	if selectors > 1 {
+		return nil, ErrMultipleEpisodeSelectors
+	}
+
+	episodes = sceneNumbered(series, episodes)
+
+	var ids []int
+	switch {
+	case len(f.Absolute) > 0:
+		for _, part := range strings.Split(f.Absolute, ",") {
+			if !isAbsolute(part) {
+				return nil, ErrInvalidAbsolute(f.Absolute)
+			}
+		}
+
+		var err error
+		ids, err = findEpisodeFileIDs(episodes, f.Absolute)
+		if err != nil {
+			return nil, err
+		}
+	case f.Latest > 0:
+		ids = findLatestEpisodeFileIDs(episodes, f.Latest)
+	case len(f.DateRange) > 0:
+		from, to, err := parseDateRange(f.DateRange)
+		if err != nil {
+			return nil, err
+		}
+		ids = findEpisodeFileIDsByAirDate(episodes, from, to)
+	default:
+		var err error
+		ids, err = findEpisodeFileIDs(episodes, f.Episode)
+		if err != nil {
+			if series.SeriesType == SeriesTypeDaily {
+				return nil, ErrDailySeries(series.Title)
+			}
+			return nil, err
+		}
+	}
+
+	missing := len(ids) == 0
+	for _, id := range ids {
+		missing = missing || id == 0
+	}
+
+	if missing {
+		if series.SeriesType == SeriesTypeDaily && len(f.Episode) > 0 {
+			return nil, ErrDailySeries(series.Title)
+		}
+		return nil, ErrNoEpisodesFound
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,62 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectEpisodeFileIDs", func() {
+	// s01e02 has not been downloaded yet
+	episodes := []sonarr.Episode{
+		{ID: 1, SeasonNumber: 1, EpisodeNumber: 1, EpisodeFileID: 10, AbsoluteEpisodeNumber: 1, AirDate: "2016-10-02"},
+		{ID: 2, SeasonNumber: 1, EpisodeNumber: 2, AbsoluteEpisodeNumber: 2, AirDate: "2016-10-09"},
+		{ID: 3, SeasonNumber: 1, EpisodeNumber: 3, EpisodeFileID: 11, AbsoluteEpisodeNumber: 3, AirDate: "2016-10-16"},
+	}
+
+	standard := sonarr.Series{Title: "Westworld", SeriesType: "standard"}
+	daily := sonarr.Series{Title: "The Daily Show", SeriesType: SeriesTypeDaily}
+
+	It("Should return the files of the requested episodes", func() {
+		Expect(SelectEpisodeFileIDs(Flags{Episode: "s01e01,s01e03"}, standard, episodes)).To(Equal([]int{10, 11}))
+	})
+
+	It("Should skip episodes without a file within a range", func() {
+		Expect(SelectEpisodeFileIDs(Flags{Episode: "s01e01-s01e03"}, standard, episodes)).To(Equal([]int{10, 11}))
+	})
+
+	It("Should return an error if a requested episode does not exist", func() {
+		_, err := SelectEpisodeFileIDs(Flags{Episode: "s01e09"}, standard, episodes)
+		Expect(err).To(MatchError(ErrNoEpisodesFound))
+	})
+
+	It("Should return an error if a requested episode has no file", func() {
+		_, err := SelectEpisodeFileIDs(Flags{Episode: "s01e01,s01e02"}, standard, episodes)
+		Expect(err).To(MatchError(ErrNoEpisodesFound))
+	})
+
+	It("Should return an error for a malformed episode", func() {
+		_, err := SelectEpisodeFileIDs(Flags{Episode: "s1e2"}, standard, episodes)
+		Expect(err).To(MatchError(ErrInvalidEpisode("s1e2")))
+		Expect(ExitCode(err)).To(Equal(ExitUsage))
+
+		_, err = SelectEpisodeFileIDs(Flags{Episode: "s01e01,foo"}, standard, episodes)
+		Expect(err.Error()).To(Equal(ErrInvalidEpisode("foo").Error()))
+
+		_, err = SelectEpisodeFileIDs(Flags{Episode: "s1e2-s1e5"}, standard, episodes)
+		Expect(err).To(MatchError(ErrInvalidEpisode("s1e2")))
+	})
+
+	It("Should suggest air dates for a daily series", func() {
+		_, err := SelectEpisodeFileIDs(Flags{Episode: "s01e09"}, daily, episodes)
+		Expect(err).To(MatchError(ErrDailySeries("The Daily Show")))
+	})
+
+	It("Should return the latest files once", func() {
+		doubled := append([]sonarr.Episode{}, episodes...)
+		doubled[1].EpisodeFileID = 11
+		Expect(SelectEpisodeFileIDs(Flags{Latest: 2}, standard, doubled)).To(Equal([]int{10, 11}))
+	})
+})
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lgug2z/sgrab/sonarr"
+)
+
+// SeriesTypeAnime is the Sonarr series type of anime, whose episodes are
+// usually known by their absolute number rather than by season.
+const SeriesTypeAnime = "anime"
+
+var absoluteRegex = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// isAbsolute reports whether s is an absolute episode number, e.g. "153", or
+// a range of them, e.g. "150-160".
+func isAbsolute(s string) bool {
+	return absoluteRegex.MatchString(s)
+}
+
+// parseAbsolute parses an absolute episode number or range of them.
+func parseAbsolute(s string) (from, to int, err error) {
+	bounds := strings.SplitN(s, "-", 2)
+
+	from, err = strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to = from
+	if len(bounds) == 2 {
+		to, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return from, to, nil
+}
+
+// findEpisodeFileIDsByAbsolute returns the file IDs of the episodes with an
+// absolute number between from and to inclusive. Episodes without a file are
+// skipped.
+func findEpisodeFileIDsByAbsolute(episodes []sonarr.Episode, from, to int) []int {
+	var ids []int
+	for _, e := range episodes {
+		if e.EpisodeFileID == 0 || e.AbsoluteEpisodeNumber == 0 {
+			continue
+		}
+
+		if e.AbsoluteEpisodeNumber >= from && e.AbsoluteEpisodeNumber <= to {
+			ids = append(ids, e.EpisodeFileID)
+		}
+	}
+
+	return ids
+}
+
+// sceneNumbered returns episodes numbered as they were released rather than
+// as TheTVDB numbers them, for series that use scene numbering. Episodes
+// without a scene mapping keep their own numbers.
+func sceneNumbered(series sonarr.Series, episodes []sonarr.Episode) []sonarr.Episode {
+	if !series.UseSceneNumbering {
+		return episodes
+	}
+
+	numbered := make([]sonarr.Episode, len(episodes))
+	for i, e := range episodes {
+		if e.SceneSeasonNumber > 0 || e.SceneEpisodeNumber > 0 {
+			e.SeasonNumber, e.EpisodeNumber = e.SceneSeasonNumber, e.SceneEpisodeNumber
+		}
+		numbered[i] = e
+	}
+
+	return numbered
+}
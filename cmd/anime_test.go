@@ -0,0 +1,141 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Episode numbering", func() {
+	var (
+		server  *ghttp.Server
+		client  sonarr.Client
+		out     *bytes.Buffer
+		restore func()
+	)
+
+	// planned returns the episode files in the plan written by a dry run
+	planned := func() []int {
+		var ids []int
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var e struct {
+				Event         string `json:"event"`
+				EpisodeFileID int    `json:"episodeFileId"`
+			}
+			Expect(json.Unmarshal([]byte(line), &e)).To(Succeed())
+			if e.Event == EventPlan {
+				ids = append(ids, e.EpisodeFileID)
+			}
+		}
+		return ids
+	}
+
+	serve := func(series sonarr.Series, episodes []sonarr.Episode) {
+		server.RouteToHandler("GET", "/api/series/", ghttp.RespondWithJSONEncoded(http.StatusOK, []sonarr.Series{series}))
+		server.RouteToHandler("GET", "/api/episode/", ghttp.RespondWithJSONEncoded(http.StatusOK, episodes))
+		server.RouteToHandler("GET", regexp.MustCompile(`^/api/episodeFile/\d+$`), func(w http.ResponseWriter, r *http.Request) {
+			id := strings.TrimPrefix(r.URL.Path, "/api/episodeFile/")
+			fmt.Fprintf(w, `{"id":%s,"path":"/tv/%s/%s.mkv","size":1000}`, id, series.Title, id)
+		})
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = sonarr.Client{URL: server.URL(), APIKey: "key", Client: http.Client{}}
+		out = &bytes.Buffer{}
+		restore = SetOutput(out)
+	})
+
+	AfterEach(func() {
+		restore()
+		server.Close()
+	})
+
+	Describe("When grabbing anime by absolute number", func() {
+		// Absolute episodes 141 to 170 make up season 2, and 155 has no file
+		var episodes []sonarr.Episode
+		for n := 141; n <= 170; n++ {
+			e := sonarr.Episode{ID: n, SeriesID: 1, SeasonNumber: 2, EpisodeNumber: n - 140, AbsoluteEpisodeNumber: n, EpisodeFileID: n, HasFile: true}
+			if n == 155 {
+				e.EpisodeFileID, e.HasFile = 0, false
+			}
+			episodes = append(episodes, e)
+		}
+
+		series := sonarr.Series{Title: "One Piece", ID: 1, SeriesType: SeriesTypeAnime}
+		f := Flags{APIKey: "key", DryRun: true, Output: OutputJSON, Series: "One Piece", SonarrURL: "http://sonarr/"}
+
+		BeforeEach(func() {
+			serve(series, episodes)
+		})
+
+		It("Should grab the episodes with a file within --absolute 150-160", func() {
+			f := f
+			f.Absolute = "150-160"
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{150, 151, 152, 153, 154, 156, 157, 158, 159, 160}))
+		})
+
+		It("Should grab a single episode given to --episode by its absolute number", func() {
+			f := f
+			f.Episode = "153"
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{153}))
+		})
+
+		It("Should still accept season and episode numbers", func() {
+			f := f
+			f.Episode = "s02e13"
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{153}))
+		})
+
+		It("Should return an error for an invalid --absolute", func() {
+			f := f
+			f.Absolute = "s02e13"
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(MatchError(ErrInvalidAbsolute("s02e13")))
+		})
+	})
+
+	Describe("When grabbing a series that uses scene numbering", func() {
+		// TheTVDB swaps the second and third episodes relative to their release
+		episodes := []sonarr.Episode{
+			{ID: 1, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 1, EpisodeFileID: 1, HasFile: true},
+			{ID: 2, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 2, SceneSeasonNumber: 1, SceneEpisodeNumber: 3, EpisodeFileID: 2, HasFile: true},
+			{ID: 3, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 3, SceneSeasonNumber: 1, SceneEpisodeNumber: 2, EpisodeFileID: 3, HasFile: true},
+		}
+
+		f := Flags{APIKey: "key", DryRun: true, Output: OutputJSON, Series: "Firefly", Episode: "s01e02", SonarrURL: "http://sonarr/"}
+
+		It("Should select episodes by their scene numbers", func() {
+			serve(sonarr.Series{Title: "Firefly", ID: 1, UseSceneNumbering: true}, episodes)
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{3}))
+		})
+
+		It("Should keep the numbers of episodes without a scene mapping", func() {
+			f := f
+			f.Episode = "s01e01"
+			serve(sonarr.Series{Title: "Firefly", ID: 1, UseSceneNumbering: true}, episodes)
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{1}))
+		})
+
+		It("Should use TheTVDB numbers if scene numbering is not used", func() {
+			serve(sonarr.Series{Title: "Firefly", ID: 1}, episodes)
+			Expect(SGrab(afero.NewMemMapFs(), f, client)).To(Succeed())
+			Expect(planned()).To(Equal([]int{2}))
+		})
+	})
+})
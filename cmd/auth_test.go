@@ -0,0 +1,306 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newKeyFile writes a new unencrypted private key to path and returns its
+// signer.
+func newKeyFile(path string) ssh.Signer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	block, err := ssh.MarshalPrivateKey(private, "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600)).To(Succeed())
+
+	signer, err := ssh.NewSignerFromKey(private)
+	Expect(err).NotTo(HaveOccurred())
+
+	return signer
+}
+
+// startAgent serves keys as an ssh-agent listening on SSH_AUTH_SOCK.
+func startAgent(dir string, keys ...interface{}) net.Listener {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		Expect(keyring.Add(agent.AddedKey{PrivateKey: key})).To(Succeed())
+	}
+
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	Expect(err).NotTo(HaveOccurred())
+	os.Setenv("SSH_AUTH_SOCK", socket)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	return l
+}
+
+var _ = Describe("Authentication", func() {
+	var (
+		dir     string
+		socket  string
+		hadSock bool
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sgrab-auth")
+		Expect(err).NotTo(HaveOccurred())
+
+		// Start each spec without an ssh-agent
+		socket, hadSock = os.LookupEnv("SSH_AUTH_SOCK")
+		os.Unsetenv("SSH_AUTH_SOCK")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("SSH_AUTH_SOCK")
+		if hadSock {
+			os.Setenv("SSH_AUTH_SOCK", socket)
+		}
+
+		os.RemoveAll(dir)
+	})
+
+	Describe("When an ssh-agent is running", func() {
+		var (
+			keyFile  string
+			agentKey ed25519.PrivateKey
+			l        net.Listener
+		)
+
+		BeforeEach(func() {
+			var err error
+			_, agentKey, err = ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			keyFile = filepath.Join(dir, "id_ed25519")
+			l = startAgent(dir, agentKey)
+		})
+
+		AfterEach(func() {
+			l.Close()
+		})
+
+		It("Should offer the keys held by the agent before the key file", func() {
+			key := newKeyFile(keyFile)
+
+			signers, err := GetSigners(Flags{SSHKeyLocation: keyFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers).To(HaveLen(2))
+			Expect(signers[0].PublicKey().Marshal()).To(Equal(sshPublicKey(agentKey).Marshal()))
+			Expect(signers[1].PublicKey().Marshal()).To(Equal(key.PublicKey().Marshal()))
+		})
+
+		It("Should not offer the key file if the agent already holds it", func() {
+			block, err := ssh.MarshalPrivateKey(agentKey, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)).To(Succeed())
+
+			signers, err := GetSigners(Flags{SSHKeyLocation: keyFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers).To(HaveLen(1))
+		})
+
+		It("Should offer the agent keys alone if there is no key file", func() {
+			signers, err := GetSigners(Flags{SSHKeyLocation: keyFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers).To(HaveLen(1))
+		})
+	})
+
+	Describe("When the key file is encrypted", func() {
+		var (
+			keyFile string
+			asked   string
+			f       Flags
+		)
+
+		BeforeEach(func() {
+			keyFile = filepath.Join(dir, "id_ecdsa")
+			asked = filepath.Join(dir, "asked")
+
+			// The command leaves a file behind, so that specs can tell when
+			// the passphrase was asked for
+			f = Flags{
+				SSHKeyLocation:          keyFile,
+				SSHKeyPassphraseCommand: fmt.Sprintf("touch %s && echo secret", asked),
+			}
+		})
+
+		Describe("When its public key is in a .pub file", func() {
+			var key *ecdsa.PrivateKey
+
+			BeforeEach(func() {
+				key = newEncryptedPEMKeyFile(keyFile, "secret")
+
+				pub, err := ssh.NewPublicKey(key.Public())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(keyFile+".pub", ssh.MarshalAuthorizedKey(pub), 0644)).To(Succeed())
+			})
+
+			It("Should not ask for the passphrase until a signature is needed", func() {
+				signers, err := GetSigners(f)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(signers).To(HaveLen(1))
+				Expect(asked).NotTo(BeAnExistingFile())
+
+				signature, err := signers[0].Sign(rand.Reader, []byte("data"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(asked).To(BeAnExistingFile())
+				Expect(signers[0].PublicKey().Verify([]byte("data"), signature)).To(Succeed())
+			})
+		})
+
+		It("Should ask for the passphrase up front if its public key is unknown", func() {
+			newEncryptedPEMKeyFile(keyFile, "secret")
+
+			signers, err := GetSigners(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers).To(HaveLen(1))
+			Expect(asked).To(BeAnExistingFile())
+		})
+
+		It("Should not ask for the passphrase if the agent holds keys and its public key is unknown", func() {
+			newEncryptedPEMKeyFile(keyFile, "secret")
+
+			_, agentKey, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			l := startAgent(dir, agentKey)
+			defer l.Close()
+
+			signers, err := GetSigners(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers).To(HaveLen(1))
+			Expect(asked).NotTo(BeAnExistingFile())
+		})
+
+		It("Should return an error for an incorrect passphrase", func() {
+			_, private, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			block, err := ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte("secret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)).To(Succeed())
+
+			// Unlike the legacy PEM format, a wrong passphrase is always
+			// detected when decrypting an OpenSSH key file
+			f.SSHKeyPassphrase = "wrong"
+
+			signers, err := GetSigners(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signers[0].PublicKey().Marshal()).To(Equal(sshPublicKey(private).Marshal()))
+
+			_, err = signers[0].Sign(rand.Reader, []byte("data"))
+			Expect(err).To(Equal(ErrIncorrectPassphrase))
+		})
+	})
+
+	Describe("When reading a secret", func() {
+		It("Should prefer the value to the command", func() {
+			Expect(ReadSecret("value", "echo command", "")).To(Equal([]byte("value")))
+		})
+
+		It("Should trim the trailing newline from the output of the command", func() {
+			Expect(ReadSecret("", "printf 'pass word\\n'", "")).To(Equal([]byte("pass word")))
+		})
+
+		It("Should return an error if the command fails", func() {
+			_, err := ReadSecret("", "exit 1", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should return an error if there is no terminal to prompt on", func() {
+			_, err := ReadSecret("", "", "Password: ")
+			Expect(err).To(Equal(ErrNoTerminal))
+		})
+	})
+
+	Describe("When choosing auth methods", func() {
+		It("Should return an error for an unknown method", func() {
+			_, err := GetAuthMethods(Flags{AuthMethods: []string{AuthPassword, "gssapi"}})
+			Expect(err).To(MatchError(ErrUnknownAuthMethod("gssapi")))
+		})
+
+		It("Should return the key error if public keys are the only method and none can be used", func() {
+			_, err := GetAuthMethods(Flags{AuthMethods: []string{AuthPublicKey}, SSHKeyLocation: filepath.Join(dir, "id_ed25519")})
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("Should fall back to a password when there is no key to offer", func() {
+			remote := filepath.Join(dir, "S01E01.mkv")
+			Expect(ioutil.WriteFile(remote, []byte("the original"), 0644)).To(Succeed())
+
+			seedbox := startTestSeedbox(0)
+			defer seedbox.Close()
+
+			f := seedbox.Flags(filepath.Join(dir, "known_hosts"))
+			f.AuthMethods = nil
+			f.SSHKeyLocation = filepath.Join(dir, "id_ed25519")
+			f.Password = ""
+			f.PasswordCommand = "echo secret"
+
+			fs := afero.NewMemMapFs()
+			Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", false)).To(Succeed())
+			Expect(afero.ReadFile(fs, "/tv/S01E01.mkv")).To(Equal([]byte("the original")))
+		})
+	})
+
+	It("Should return an error if there is neither an agent nor a key file", func() {
+		_, err := GetSigners(Flags{SSHKeyLocation: filepath.Join(dir, "id_ed25519")})
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
+
+// newEncryptedPEMKeyFile writes a new private key to path in the legacy PEM
+// format, which does not include the public key, encrypted with passphrase.
+func newEncryptedPEMKeyFile(path, passphrase string) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	der, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte(passphrase), x509.PEMCipherAES256)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600)).To(Succeed())
+
+	return key
+}
+
+// sshPublicKey returns the SSH public key of key.
+func sshPublicKey(key crypto.Signer) ssh.PublicKey {
+	pub, err := ssh.NewPublicKey(key.Public())
+	Expect(err).NotTo(HaveOccurred())
+
+	return pub
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// transfer is a single episode file to grab from the seedbox. Resumable
+// transfers carry on from a partial local file, which is kept if they fail.
+type transfer struct {
+	Series        string
+	SeriesID      int
+	EpisodeFileID int
+	EpisodeIDs    []int
+	Remote        string
+	Dst           string
+	Size          int64
+	Resume        bool
+
+	bar      *pb.ProgressBar
+	started  bool
+	done     bool
+	err      error
+	written  int64
+	duration time.Duration
+}
+
+// batch downloads a set of transfers concurrently over a shared session. A
+// failed transfer does not stop the others.
+type batch struct {
+	fs        afero.Fs
+	f         Flags
+	s         *session
+	transfers []*transfer
+	onDone    func(*transfer)
+
+	mu       sync.Mutex
+	pool     *pb.Pool
+	stopOnce sync.Once
+}
+
+// Run downloads every transfer using up to --jobs workers, each with its own
+// progress bar, and prints a summary once they have all finished. With JSON
+// output, progress events are emitted instead. If there is a single transfer
+// its error is returned as is.
+func (b *batch) Run() error {
+	var size int64
+	var bars []*pb.ProgressBar
+	for _, t := range b.transfers {
+		t.bar = newProgressBar(t.Size, filepath.Base(t.Dst))
+		bars = append(bars, t.bar)
+		size += t.Size
+	}
+
+	total := newProgressBar(size, "Total")
+	if len(b.transfers) > 1 {
+		bars = append(bars, total)
+	}
+
+	// Without a terminal there is nowhere to draw the bars, so only the
+	// summary is printed
+	var pool *pb.Pool
+	var err error
+	if b.f.Output == OutputJSON {
+		err = ErrNoTerminal
+	} else {
+		pool, err = pb.StartPool(bars...)
+	}
+
+	if err != nil {
+		for _, bar := range bars {
+			bar.NotPrint = true
+		}
+	} else {
+		b.pool = pool
+	}
+
+	stopProgress := make(chan struct{})
+	if b.f.Output == OutputJSON {
+		go b.emitProgress(stopProgress)
+	}
+
+	jobs := b.f.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	queue := make(chan *transfer)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				b.run(t, total)
+			}
+		}()
+	}
+
+	for _, t := range b.transfers {
+		queue <- t
+	}
+	close(queue)
+	wg.Wait()
+
+	close(stopProgress)
+	b.stopPool()
+
+	// The error event for the transfer has already been emitted
+	if len(b.transfers) == 1 {
+		if err := b.transfers[0].err; err != nil {
+			return reportedError{err}
+		}
+		return nil
+	}
+
+	if b.f.Output != OutputJSON {
+		b.printSummary(os.Stdout)
+	}
+
+	failed := 0
+	for _, t := range b.transfers {
+		if t.err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return ErrTransfersFailed(failed, len(b.transfers))
+	}
+
+	return nil
+}
+
+func (b *batch) run(t *transfer, total io.Writer) {
+	b.mu.Lock()
+	t.started = true
+	b.mu.Unlock()
+
+	emit(b.f, event{Event: EventStart, Series: t.Series, EpisodeFileID: t.EpisodeFileID, Remote: t.Remote, Path: t.Dst, Size: t.Size})
+
+	start := time.Now()
+	err := copyFile(b.fs, b.s, t.Remote, t.Dst, t.Resume, t.bar, total)
+	written := t.bar.Get()
+	if err == nil && b.f.WithExtras {
+		err = copyExtras(b.fs, b.s, t.Remote, t.Dst, t.bar)
+	}
+	t.bar.Finish()
+
+	if err != nil {
+		emitError(b.f, event{Series: t.Series, EpisodeFileID: t.EpisodeFileID, Remote: t.Remote, Path: t.Dst}, err)
+	} else if b.f.Output == OutputJSON {
+		hash, hashErr := hashFile(b.fs, t.Dst)
+		if hashErr != nil {
+			err = hashErr
+			emitError(b.f, event{Series: t.Series, EpisodeFileID: t.EpisodeFileID, Remote: t.Remote, Path: t.Dst}, err)
+		} else {
+			emit(b.f, event{Event: EventComplete, Series: t.Series, EpisodeFileID: t.EpisodeFileID, Remote: t.Remote, Path: t.Dst, Size: written, SHA256: hash})
+		}
+	}
+
+	b.mu.Lock()
+	t.done = true
+	t.err = err
+	t.written = written
+	t.duration = time.Since(start)
+	b.mu.Unlock()
+
+	if b.onDone != nil {
+		b.onDone(t)
+	}
+}
+
+// emitProgress emits a progress event for every transfer in progress each
+// second until stop is closed.
+func (b *batch) emitProgress(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		b.mu.Lock()
+		var running []*transfer
+		for _, t := range b.transfers {
+			if t.started && !t.done {
+				running = append(running, t)
+			}
+		}
+		b.mu.Unlock()
+
+		for _, t := range running {
+			emit(b.f, event{
+				Event:         EventProgress,
+				EpisodeFileID: t.EpisodeFileID,
+				Path:          t.Dst,
+				Size:          atomic.LoadInt64(&t.bar.Total),
+				Transferred:   t.bar.Get(),
+			})
+		}
+	}
+}
+
+func (b *batch) stopPool() {
+	b.stopOnce.Do(func() {
+		if b.pool != nil {
+			b.pool.Stop()
+		}
+	})
+}
+
+// Cleanup stops drawing progress and removes the local files of every
+// transfer that started but did not complete, unless it can be resumed.
+func (b *batch) Cleanup() error {
+	b.stopPool()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var cleanupErr error
+	for _, t := range b.transfers {
+		if !t.started || t.Resume || (t.done && t.err == nil) {
+			continue
+		}
+
+		if err := b.fs.Remove(t.Dst); err != nil && !os.IsNotExist(err) {
+			cleanupErr = err
+		}
+	}
+
+	return cleanupErr
+}
+
+func (b *batch) printSummary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSTATUS\tSIZE\tDURATION\tAVG SPEED")
+
+	for _, t := range b.transfers {
+		status := "done"
+		if t.err != nil {
+			status = fmt.Sprintf("failed: %v", t.err)
+		}
+
+		speed := "-"
+		if seconds := t.duration.Seconds(); seconds > 0 && t.err == nil {
+			speed = fmt.Sprintf("%s/s", pb.Format(int64(float64(t.written)/seconds)).To(pb.U_BYTES))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			filepath.Base(t.Dst),
+			status,
+			pb.Format(t.written).To(pb.U_BYTES),
+			t.duration.Round(time.Second),
+			speed,
+		)
+	}
+
+	tw.Flush()
+}
+
+func newProgressBar(size int64, name string) *pb.ProgressBar {
+	bar := pb.New64(size).SetUnits(pb.U_BYTES).Prefix(fmt.Sprintf("%s ", name))
+	bar.ShowSpeed = true
+	return bar
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var cacheDir = filepath.Join(os.Getenv("HOME"), ".sgrab", "cache")
+
+// cacheEntry is a Sonarr response kept on disk along with the validators
+// needed to ask Sonarr whether it has changed.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Fetched      time.Time `json:"fetched"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+// cacheTransport caches the series and episode lists fetched from Sonarr on
+// disk. Responses younger than ttl are used without contacting Sonarr. Older
+// ones are revalidated using their ETag or Last-Modified date, so an unchanged
+// list is not downloaded again. With refresh set, cached responses are always
+// revalidated.
+type cacheTransport struct {
+	fs      afero.Fs
+	dir     string
+	ttl     time.Duration
+	refresh bool
+	next    http.RoundTripper
+}
+
+// cacheable reports whether req is for a list that is worth caching.
+func cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	return strings.HasSuffix(req.URL.Path, sonarr.APIEndpoint+sonarr.SeriesEndpoint) ||
+		strings.HasSuffix(req.URL.Path, sonarr.APIEndpoint+sonarr.EpisodeEndpoint)
+}
+
+// path returns the cache file for req. The API key is part of the name so
+// that a rejected key is never answered from the cache.
+func (t *cacheTransport) path(req *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", req.URL.String(), req.Header.Get("X-Api-Key"))
+	return filepath.Join(t.dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+func (t *cacheTransport) load(path string) (*cacheEntry, bool) {
+	buf, err := afero.ReadFile(t.fs, path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+// save writes entry to path. Failing to do so only makes the next run slower,
+// so errors are logged rather than returned.
+func (t *cacheTransport) save(path string, entry *cacheEntry) {
+	buf, err := json.Marshal(entry)
+	if err == nil {
+		err = t.fs.MkdirAll(t.dir, 0700)
+	}
+	if err == nil {
+		err = afero.WriteFile(t.fs, path, buf, 0600)
+	}
+	if err != nil {
+		logger.Info("writing sonarr cache failed", "url", entry.URL, "error", err)
+	}
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.path(req)
+	entry, cached := t.load(path)
+
+	if cached && !t.refresh && time.Since(entry.Fetched) < t.ttl {
+		logger.Debug("sonarr cache hit", "url", entry.URL, "age", time.Since(entry.Fetched).Round(time.Second))
+		return entry.response(req), nil
+	}
+
+	if cached {
+		req = req.Clone(req.Context())
+		if len(entry.ETag) > 0 {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if len(entry.LastModified) > 0 {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		logger.Debug("sonarr cache revalidated", "url", entry.URL)
+
+		entry.Fetched = time.Now()
+		t.save(path, entry)
+		return entry.response(req), nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.save(path, &cacheEntry{
+		URL:          req.URL.String(),
+		Fetched:      time.Now(),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Body:         body,
+	})
+
+	return res, nil
+}
+
+// response makes a response to req from the cached body.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of Sonarr series and episode lists.",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove everything from the cache.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return clearCache(afero.NewOsFs(), cacheDir, os.Stdout)
+	},
+}
+
+func clearCache(fs afero.Fs, dir string, w io.Writer) error {
+	if err := fs.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Cleared %s.\n", dir)
+	return err
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	RootCmd.AddCommand(cacheCmd)
+}
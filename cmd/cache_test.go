@@ -0,0 +1,148 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"net/http"
+	"time"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Sonarr cache", func() {
+	var (
+		server *ghttp.Server
+		fs     afero.Fs
+	)
+
+	series := []sonarr.Series{{Title: "Westworld", ID: 1}}
+	validators := http.Header{
+		"ETag":          {`"v1"`},
+		"Last-Modified": {"Sun, 02 Oct 2016 21:00:00 GMT"},
+	}
+
+	client := func(key string, ttl time.Duration, refresh bool) sonarr.Client {
+		return sonarr.Client{
+			URL:    server.URL(),
+			APIKey: key,
+			Client: http.Client{Transport: NewCacheTransport(fs, "/cache", ttl, refresh)},
+		}
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		fs = afero.NewMemMapFs()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("When a list was fetched within the TTL", func() {
+		It("Should be answered from disk", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series),
+				),
+			)
+
+			Expect(client("key", time.Hour, false).Series()).To(Equal(series))
+			Expect(client("key", time.Hour, false).Series()).To(Equal(series))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Describe("When a list is older than the TTL", func() {
+		It("Should revalidate it and use it if it has not changed", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series, validators),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.VerifyHeaderKV("If-None-Match", `"v1"`),
+					ghttp.VerifyHeaderKV("If-Modified-Since", "Sun, 02 Oct 2016 21:00:00 GMT"),
+					ghttp.RespondWith(http.StatusNotModified, ""),
+				),
+			)
+
+			Expect(client("key", 0, false).Series()).To(Equal(series))
+			Expect(client("key", 0, false).Series()).To(Equal(series))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Describe("When called with --refresh", func() {
+		It("Should revalidate a list fetched within the TTL", func() {
+			changed := []sonarr.Series{{Title: "Westworld", ID: 1}, {Title: "Atlanta", ID: 2}}
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series, validators),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.VerifyHeaderKV("If-None-Match", `"v1"`),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, changed),
+				),
+			)
+
+			Expect(client("key", time.Hour, false).Series()).To(Equal(series))
+			Expect(client("key", time.Hour, true).Series()).To(Equal(changed))
+			Expect(client("key", time.Hour, false).Series()).To(Equal(changed))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Describe("When Sonarr does not answer with a list", func() {
+		It("Should not cache a rejected API key", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusUnauthorized, ""),
+				ghttp.RespondWith(http.StatusUnauthorized, ""),
+			)
+
+			_, err := client("wrong", time.Hour, false).Series()
+			Expect(err).To(MatchError(sonarr.ErrUnauthorized))
+			_, err = client("wrong", time.Hour, false).Series()
+			Expect(err).To(MatchError(sonarr.ErrUnauthorized))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("Should not cache an error", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusInternalServerError, "[]"),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, series),
+			)
+
+			client("key", time.Hour, false).Series()
+			Expect(client("key", time.Hour, false).Series()).To(Equal(series))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Describe("When a different API key is used", func() {
+		It("Should not be answered from the cache", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("X-Api-Key", "key"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("X-Api-Key", "wrong"),
+					ghttp.RespondWith(http.StatusUnauthorized, ""),
+				),
+			)
+
+			Expect(client("key", time.Hour, false).Series()).To(Equal(series))
+			_, err := client("wrong", time.Hour, false).Series()
+			Expect(err).To(MatchError(sonarr.ErrUnauthorized))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+})
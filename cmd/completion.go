@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completeSeries completes --series with the names of the series on Sonarr.
+// They are usually answered from the cache, so completion stays quick.
+func completeSeries(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, f, c, err := setup(cmd)
+	if err != nil || !hasSonarrFlags(f) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	series, err := c.Series()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var titles []string
+	for _, s := range series {
+		if strings.HasPrefix(strings.ToLower(s.Title), strings.ToLower(toComplete)) {
+			titles = append(titles, s.Title)
+		}
+	}
+
+	return titles, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeEpisode completes --episode with the episodes of the requested
+// series that have a file, after any episodes already listed or the
+// start of a range.
+func completeEpisode(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeEpisodes(cmd, toComplete, false)
+}
+
+// completeDateRange completes either end of --date-range with the air dates of
+// the episodes of the requested series that have a file.
+func completeDateRange(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeEpisodes(cmd, toComplete, true)
+}
+
+func completeEpisodes(cmd *cobra.Command, toComplete string, byAirDate bool) ([]string, cobra.ShellCompDirective) {
+	_, f, c, err := setup(cmd)
+	if err != nil || !hasSonarrFlags(f) || !hasSeriesSelector(f) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	requestedSeries, err := findRequestedSeries(f, c)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	episodes, err := c.Episodes(requestedSeries.ID)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	episodes = sceneNumbered(requestedSeries, episodes)
+	byAirDate = byAirDate || requestedSeries.SeriesType == SeriesTypeDaily
+	prefix := completionPrefix(toComplete, byAirDate)
+
+	var completions []string
+	for _, e := range episodes {
+		if !e.HasFile {
+			continue
+		}
+
+		if byAirDate {
+			if len(e.AirDate) > 0 {
+				completions = append(completions, fmt.Sprintf("%s%s\t%s", prefix, e.AirDate, e.Title))
+			}
+			continue
+		}
+
+		if requestedSeries.SeriesType == SeriesTypeAnime && e.AbsoluteEpisodeNumber > 0 {
+			completions = append(completions, fmt.Sprintf("%s%d\t%s", prefix, e.AbsoluteEpisodeNumber, e.Title))
+			continue
+		}
+
+		completions = append(completions, fmt.Sprintf("%ss%02de%02d\t%s", prefix, e.SeasonNumber, e.EpisodeNumber, e.Title))
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completionPrefix returns the part of toComplete that is kept in front of
+// each completion: any episodes already listed and the start of a range. Air
+// dates contain dashes themselves, so only "," and ".." separate them.
+func completionPrefix(toComplete string, byAirDate bool) string {
+	if !byAirDate {
+		return toComplete[:strings.LastIndexAny(toComplete, ",-")+1]
+	}
+
+	cut := strings.LastIndex(toComplete, ",") + 1
+	if i := strings.LastIndex(toComplete, ".."); i >= 0 && i+2 > cut {
+		cut = i + 2
+	}
+
+	return toComplete[:cut]
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate a shell completion script.",
+	Long: `Generate a completion script for bash, zsh or fish. Series names are
+completed from Sonarr, and episodes with a file from the series given with
+--series.
+
+To load completions in the current shell:
+
+  bash: source <(sgrab completion bash)
+  zsh:  source <(sgrab completion zsh)
+  fish: sgrab completion fish | source`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return RootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return RootCmd.GenZshCompletion(os.Stdout)
+		default:
+			return RootCmd.GenFishCompletion(os.Stdout, true)
+		}
+	},
+}
+
+func init() {
+	RootCmd.CompletionOptions.DisableDefaultCmd = true
+	RootCmd.AddCommand(completionCmd)
+}
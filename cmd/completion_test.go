@@ -0,0 +1,25 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CompletionPrefix", func() {
+	table.DescribeTable("Should keep what has already been typed",
+		func(toComplete string, byAirDate bool, prefix string) {
+			Expect(CompletionPrefix(toComplete, byAirDate)).To(Equal(prefix))
+		},
+		table.Entry("nothing typed", "", false, ""),
+		table.Entry("a partial episode", "s01e0", false, ""),
+		table.Entry("the start of a range", "s01e01-s01", false, "s01e01-"),
+		table.Entry("a list of episodes", "s01e01,s01e03-s01e05,s02", false, "s01e01,s01e03-s01e05,"),
+		table.Entry("a partial air date", "2024-03", true, ""),
+		table.Entry("a list of air dates", "2024-03-15,2024-03", true, "2024-03-15,"),
+		table.Entry("the start of a date range", "2024-03-01..2024-03", true, "2024-03-01.."),
+		table.Entry("an open date range", "..2024", true, ".."),
+	)
+})
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// jumpHost is a single hop in a --jump or ProxyJump specification. Port is
+// empty if it was not specified.
+type jumpHost struct {
+	User string
+	Host string
+	Port string
+}
+
+func (j jumpHost) String() string {
+	addr := j.Host
+	if len(j.Port) > 0 {
+		addr = net.JoinHostPort(j.Host, j.Port)
+	}
+
+	if len(j.User) == 0 {
+		return addr
+	}
+
+	return fmt.Sprintf("%s@%s", j.User, addr)
+}
+
+// parseJumpHosts parses a comma separated list of [user@]host[:port] hops,
+// in the format used by ssh -J and ProxyJump.
+func parseJumpHosts(spec string) ([]jumpHost, error) {
+	if len(spec) == 0 || spec == "none" {
+		return nil, nil
+	}
+
+	var hosts []jumpHost
+	for _, hop := range strings.Split(spec, ",") {
+		j := jumpHost{}
+
+		if i := strings.LastIndex(hop, "@"); i >= 0 {
+			j.User = hop[:i]
+			hop = hop[i+1:]
+		}
+
+		if host, port, err := net.SplitHostPort(hop); err == nil {
+			j.Host, j.Port = host, port
+		} else {
+			j.Host = hop
+		}
+
+		if len(j.Host) == 0 {
+			return nil, ErrInvalidJumpHost(spec)
+		}
+
+		hosts = append(hosts, j)
+	}
+
+	return hosts, nil
+}
+
+// seedboxConn is an SSH connection to the seedbox along with the connections
+// to any jump hosts it is tunnelled through.
+type seedboxConn struct {
+	*ssh.Client
+	jumps []*ssh.Client
+	proxy proxy.Dialer
+}
+
+// dialSeedbox connects to the seedbox, tunnelling through each jump host in
+// turn. Every hop has its host key verified and is authenticated separately,
+// using the seedbox username unless the hop specifies its own.
+func dialSeedbox(f Flags, auth []ssh.AuthMethod) (*seedboxConn, error) {
+	jumps, err := parseJumpHosts(f.Jump)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := sshDialer(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &seedboxConn{proxy: dialer}
+
+	for _, j := range jumps {
+		hop := f
+		hop.SeedboxURL = j.Host
+		hop.Port = j.Port
+		if len(hop.Port) == 0 {
+			hop.Port = "22"
+		}
+		hop.HostKeyFingerprint = ""
+		if len(j.User) > 0 {
+			hop.Username = j.User
+		}
+
+		hopAuth, err := getAuthMethods(hop)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := conn.dial(hop, hopAuth); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := conn.dial(f, auth); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dial connects to the host in f, through the current connection if there is
+// one or the proxy otherwise, and makes it the current connection.
+func (c *seedboxConn) dial(f Flags, auth []ssh.AuthMethod) error {
+	addr := net.JoinHostPort(f.SeedboxURL, f.Port)
+
+	// Make sure there is an entry for the host in a known_hosts file before connecting,
+	// or that the user trusts the key presented on first use
+	knownHosts, err := getHostKeyCallback(f.KnownHosts, globalKnownHosts)
+	if err != nil {
+		return err
+	}
+
+	var hostKeyErr error
+	hostKeyCallback := checkHostKey(trustOnFirstUse(knownHosts, f.KnownHosts, f.HostKeyFingerprint))
+
+	sshConfig := &ssh.ClientConfig{
+		User: f.Username,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyErr = hostKeyCallback(hostname, remote, key)
+			if hostKeyErr != nil {
+				logger.Info("host key rejected", "host", hostname, "type", key.Type(), "fingerprint", ssh.FingerprintSHA256(key), "error", hostKeyErr)
+			} else {
+				logger.Info("host key verified", "host", hostname, "type", key.Type(), "fingerprint", ssh.FingerprintSHA256(key))
+			}
+			return hostKeyErr
+		},
+		HostKeyAlgorithms: hostKeyAlgorithms(knownHosts, addr),
+	}
+
+	var via proxy.Dialer = c.proxy
+	if c.Client != nil {
+		via = c.Client
+	}
+
+	logger.Info("connecting", "host", addr, "user", f.Username, "jump", c.Client != nil)
+
+	// Errors reaching the host are returned as they are, so that they are
+	// not mistaken for rejected credentials
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		logger.Info("connection failed", "host", addr, "error", err)
+		return err
+	}
+
+	client, err := handshake(conn, addr, sshConfig)
+	if err != nil {
+		logger.Info("handshake failed", "host", addr, "error", err)
+		if hostKeyErr != nil {
+			return hostKeyErr
+		}
+
+		// e.g. an incorrect passphrase for a key the seedbox accepted
+		var e *Error
+		if errors.As(err, &e) {
+			return e
+		}
+		return ErrCredentialsRejected
+	}
+
+	logger.Info("connected", "host", addr, "server", string(client.ServerVersion()))
+
+	if c.Client != nil {
+		c.jumps = append(c.jumps, c.Client)
+	}
+	c.Client = client
+
+	return nil
+}
+
+// handshake makes an SSH connection to addr over conn, which was made through
+// a proxy or an existing SSH connection. conn is closed if the handshake
+// fails.
+func handshake(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// Close closes the connection to the seedbox and then every jump host.
+func (c *seedboxConn) Close() error {
+	var err error
+	if c.Client != nil {
+		err = c.Client.Close()
+	}
+
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		c.jumps[i].Close()
+	}
+
+	return err
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/afero"
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// printPlan lists the transfers that would be made, with what is already at
+// each destination and the total size, without connecting to the seedbox.
+func printPlan(fs afero.Fs, f Flags, transfers []*transfer, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if f.Output != OutputJSON {
+		fmt.Fprintln(tw, "FILE\tSOURCE\tDESTINATION\tSIZE\tSTATUS")
+	}
+
+	var total int64
+	for _, t := range transfers {
+		var existing int64
+		status := "new"
+
+		info, err := fs.Stat(t.Dst)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		case t.Resume && info.Size() <= t.Size:
+			existing = info.Size()
+			status = fmt.Sprintf("resume from %s", pb.Format(existing).To(pb.U_BYTES))
+		default:
+			existing = info.Size()
+			status = "exists, will be overwritten"
+		}
+
+		total += t.Size
+
+		emit(f, event{Event: EventPlan, Series: t.Series, EpisodeFileID: t.EpisodeFileID, Remote: t.Remote, Path: t.Dst, Size: t.Size, Transferred: existing})
+		if f.Output == OutputJSON {
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			filepath.Base(t.Dst),
+			t.Remote,
+			t.Dst,
+			pb.Format(t.Size).To(pb.U_BYTES),
+			status,
+		)
+	}
+
+	if f.Output == OutputJSON {
+		return nil
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d episode(s), %s in total. Nothing was grabbed (--dry-run).\n", len(transfers), pb.Format(total).To(pb.U_BYTES))
+	return err
+}
@@ -0,0 +1,148 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Dry run", func() {
+	var (
+		server  *ghttp.Server
+		client  sonarr.Client
+		fs      afero.Fs
+		out     *bytes.Buffer
+		restore func()
+		pwd     string
+	)
+
+	series := []sonarr.Series{{Title: "Westworld", ID: 1}}
+	episodes := []sonarr.Episode{
+		{Title: "The Original", ID: 1, EpisodeFileID: 1, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 1, HasFile: true},
+		{Title: "Chestnut", ID: 2, EpisodeFileID: 2, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 2, HasFile: true},
+	}
+	episodeFiles := []sonarr.EpisodeFile{
+		{ID: 1, Path: "/data/tv/Westworld/S01E01.mkv", Size: 1000},
+		{ID: 2, Path: "/data/tv/Westworld/S01E02.mkv", Size: 2000},
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = sonarr.Client{URL: server.URL(), APIKey: "key", Client: http.Client{}}
+		fs = afero.NewMemMapFs()
+		out = &bytes.Buffer{}
+		restore = SetOutput(out)
+
+		var err error
+		pwd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		restore()
+		server.Close()
+	})
+
+	Describe("When called with --dry-run", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/series/"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episode/"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, episodes),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episodeFile/1"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, episodeFiles[0]),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episodeFile/2"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, episodeFiles[1]),
+				),
+			)
+
+			// Part of s01e02 is already at the destination
+			Expect(afero.WriteFile(fs, filepath.Join(pwd, "S01E02.mkv"), make([]byte, 500), 0644)).To(Succeed())
+		})
+
+		f := Flags{
+			APIKey:       "key",
+			DryRun:       true,
+			Episode:      "s01e01-s01e02",
+			PathMappings: []string{"/data/tv=/home/me/tv"},
+			Series:       "Westworld",
+			SonarrURL:    "http://sonarr/",
+		}
+
+		It("Should list the transfers with their mapped paths without grabbing them", func() {
+			Expect(SGrab(fs, f, client)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(4))
+
+			lines := strings.Split(out.String(), "\n")
+			Expect(lines[0]).To(MatchRegexp(`^FILE\s+SOURCE\s+DESTINATION\s+SIZE\s+STATUS$`))
+			Expect(lines[1]).To(MatchRegexp(`^S01E01\.mkv\s+/home/me/tv/Westworld/S01E01\.mkv\s+%s\s+1000 B\s+new$`, regexp.QuoteMeta(filepath.Join(pwd, "S01E01.mkv"))))
+			Expect(lines[2]).To(MatchRegexp(`^S01E02\.mkv\s+/home/me/tv/Westworld/S01E02\.mkv\s+%s\s+1\.95 KiB\s+exists, will be overwritten$`, regexp.QuoteMeta(filepath.Join(pwd, "S01E02.mkv"))))
+			Expect(out.String()).To(HaveSuffix("2 episode(s), 2.93 KiB in total. Nothing was grabbed (--dry-run).\n"))
+
+			Expect(afero.Exists(fs, filepath.Join(pwd, "S01E01.mkv"))).To(BeFalse())
+		})
+
+		It("Should emit a plan event for each transfer with --output json", func() {
+			f := f
+			f.Output = OutputJSON
+			Expect(SGrab(fs, f, client)).To(Succeed())
+
+			var plans []map[string]interface{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				var e map[string]interface{}
+				Expect(json.Unmarshal([]byte(line), &e)).To(Succeed())
+				if e["event"] == EventPlan {
+					plans = append(plans, e)
+				}
+			}
+
+			Expect(plans).To(HaveLen(2))
+			Expect(plans[0]).To(HaveKeyWithValue("remotePath", "/home/me/tv/Westworld/S01E01.mkv"))
+			Expect(plans[0]).NotTo(HaveKey("transferred"))
+			Expect(plans[1]).To(HaveKeyWithValue("path", filepath.Join(pwd, "S01E02.mkv")))
+			Expect(plans[1]).To(HaveKeyWithValue("transferred", BeNumerically("==", 500)))
+		})
+	})
+
+	Describe("When the queue is run with --dry-run", func() {
+		It("Should resume partially grabbed files and leave the queue as it is", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episodeFile/2"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, episodeFiles[1]),
+				),
+			)
+
+			dst := filepath.Join(pwd, "S01E02.mkv")
+			queue := `{"nextId":2,"items":[{"id":1,"series":"Westworld","episodeFileId":2,"destination":"` + dst + `","status":"failed"}]}`
+			Expect(afero.WriteFile(fs, "/queue.json", []byte(queue), 0600)).To(Succeed())
+			Expect(afero.WriteFile(fs, dst, make([]byte, 500), 0644)).To(Succeed())
+
+			f := Flags{APIKey: "key", DryRun: true, SonarrURL: "http://sonarr/"}
+			Expect(RunQueue(fs, f, client, "/queue.json")).To(Succeed())
+
+			Expect(out.String()).To(MatchRegexp(`S01E02\.mkv\s+/data/tv/Westworld/S01E02\.mkv\s+%s\s+1\.95 KiB\s+resume from 500 B\n`, regexp.QuoteMeta(dst)))
+			Expect(afero.ReadFile(fs, "/queue.json")).To(MatchJSON(queue))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ = Describe("ExitCode", func() {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	table.DescribeTable("Should return the documented exit code",
+		func(err error, code int) {
+			Expect(ExitCode(err)).To(Equal(code))
+		},
+		table.Entry("no error", nil, ExitOK),
+		table.Entry("an unknown error", errors.New("unknown"), ExitError),
+		table.Entry("missing information", ErrInformationMissing, ExitUsage),
+		table.Entry("an invalid flag", ErrInvalidFlag(errors.New("unknown flag: --bogus")), ExitUsage),
+		table.Entry("an invalid episode", ErrInvalidEpisode("s1e2"), ExitUsage),
+		table.Entry("an invalid path mapping", ErrInvalidPathMapping("/tv"), ExitUsage),
+		table.Entry("a rejected API key", sonarr.ErrUnauthorized, ExitUnauthorized),
+		table.Entry("a missing series", ErrCouldNotFindSeries("Westworld"), ExitNotFound),
+		table.Entry("a wrapped missing episode file", fmt.Errorf("grabbing: %w", ErrEpisodeFileNotFound("/tv/1.mkv")), ExitNotFound),
+		table.Entry("an unknown host key", ErrNoHostKey("seedbox"), ExitHostKey),
+		table.Entry("a changed host key", ErrHostKeyMismatch("seedbox", "known_hosts", 1), ExitHostKey),
+		table.Entry("rejected credentials", ErrCredentialsRejected, ExitCredentials),
+		table.Entry("an incorrect passphrase", ErrIncorrectPassphrase, ExitCredentials),
+		table.Entry("a refused connection", refused, ExitUnreachable),
+		table.Entry("an unreachable Sonarr", &url.Error{Op: "Get", URL: "http://sonarr/", Err: refused}, ExitUnreachable),
+		table.Entry("a connection refused by a jump host", &ssh.OpenChannelError{Reason: ssh.ConnectionFailed}, ExitUnreachable),
+		table.Entry("a refusing proxy", ErrProxyConnectFailed("seedbox:22", "403 Forbidden"), ExitUnreachable),
+		table.Entry("failed transfers", ErrTransfersFailed(1, 2), ExitPartial),
+		table.Entry("an interrupt", ErrInterruptReceived, ExitInterrupted),
+		table.Entry("an interrupt with failed cleanup", ErrInterruptReceivedCleanupFailed, ExitInterrupted),
+	)
+})
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Unexported functions used by the specs in package cmd_test.
+var (
+	CompletionPrefix     = completionPrefix
+	AddKnownHost         = addKnownHost
+	ApplySSHConfig       = applySSHConfig
+	Backoff              = backoff
+	ExtraDstPath         = extraDstPath
+	CheckHostKey         = checkHostKey
+	FindEpisodeFileIDs   = findEpisodeFileIDs
+	GetAuthMethods       = getAuthMethods
+	GetHostKeyCallback   = getHostKeyCallback
+	GetSigners           = getSigners
+	HTTPProxy            = httpProxy
+	HostKeyAlgorithms    = hostKeyAlgorithms
+	IsExtra              = isExtra
+	LoadQueue            = loadQueue
+	LoadSSHConfig        = loadSSHConfig
+	MapPath              = mapPath
+	MatchEpisodes        = matchEpisodes
+	NextEpisode          = nextEpisode
+	ParsePathMappings    = parsePathMappings
+	PrintError           = printError
+	ReadSecret           = readSecret
+	ResolveJumpHosts     = resolveJumpHosts
+	TrustOnFirstUse      = trustOnFirstUse
+	SSHDialer            = sshDialer
+	SelectEpisodeFileIDs = selectEpisodeFileIDs
+)
+
+// SetOutput redirects events and plans to w until the returned function is
+// called.
+func SetOutput(w io.Writer) (restore func()) {
+	previous := output
+	output = w
+	return func() { output = previous }
+}
+
+// NewCacheTransport returns the transport caching Sonarr responses in dir.
+func NewCacheTransport(fs afero.Fs, dir string, ttl time.Duration, refresh bool) http.RoundTripper {
+	return &cacheTransport{fs: fs, dir: dir, ttl: ttl, refresh: refresh, next: http.DefaultTransport}
+}
+
+// Reported marks err as already emitted as an error event.
+func Reported(err error) error {
+	return reportedError{err}
+}
+
+// NewTransfer returns a transfer of the episode file with the given ID to dst.
+func NewTransfer(series string, episodeFileID int, dst string) *transfer {
+	return &transfer{Series: series, EpisodeFileID: episodeFileID, Dst: dst}
+}
+
+// CopyFile copies the file at e on the seedbox in f to dstPath over a new
+// connection.
+func CopyFile(fs afero.Fs, f Flags, e, dstPath string, resume bool) error {
+	auth, err := getAuthMethods(f)
+	if err != nil {
+		return err
+	}
+
+	s := newSession(f, auth)
+	defer s.Close()
+
+	bar := newProgressBar(0, "")
+	bar.NotPrint = true
+
+	return copyFile(fs, s, e, dstPath, resume, bar, nil)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// extraExtensions are the extensions of the companion files Sonarr and media
+// servers keep next to an episode file, and thumbSuffix the ending of its
+// thumbnail.
+var extraExtensions = []string{".srt", ".ass", ".ssa", ".sub", ".idx", ".vtt", ".nfo"}
+
+const thumbSuffix = "-thumb.jpg"
+
+// isExtra reports whether name is a companion file of the episode file with
+// the given stem, e.g. "Show - S01E01.en.srt" or "Show - S01E01-thumb.jpg"
+// for "Show - S01E01". Apart from the thumbnail, the stem must be followed by
+// a ".", so that "Show - S01E01-S01E02.srt" belongs to another episode file.
+func isExtra(name, stem string) bool {
+	if !strings.HasPrefix(name, stem) {
+		return false
+	}
+
+	rest := strings.ToLower(strings.TrimPrefix(name, stem))
+	if rest == thumbSuffix {
+		return true
+	}
+
+	if !strings.HasPrefix(rest, ".") {
+		return false
+	}
+
+	for _, ext := range extraExtensions {
+		if strings.HasSuffix(rest, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stem(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// findExtras lists the directory of the episode file at e on the seedbox and
+// returns the paths of its companion files.
+func findExtras(s *session, e string) ([]string, error) {
+	sftp, err := s.SFTP()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := sftp.ReadDir(path.Dir(e))
+	if err != nil {
+		return nil, err
+	}
+
+	var extras []string
+	for _, fi := range files {
+		if !fi.IsDir() && isExtra(fi.Name(), stem(path.Base(e))) {
+			extras = append(extras, path.Join(path.Dir(e), fi.Name()))
+		}
+	}
+
+	return extras, nil
+}
+
+// extraDstPath returns where to save a companion file so that it is named
+// consistently with the episode file saved at dstPath.
+func extraDstPath(dstPath, e, extra string) string {
+	suffix := strings.TrimPrefix(path.Base(extra), stem(path.Base(e)))
+	return filepath.Join(filepath.Dir(dstPath), stem(filepath.Base(dstPath))+suffix)
+}
+
+// copyExtras downloads the companion files of the episode file at e alongside
+// the episode file saved at dstPath, reporting progress on bar.
+func copyExtras(fs afero.Fs, s *session, e, dstPath string, bar *pb.ProgressBar) error {
+	extras, err := findExtras(s, e)
+	if err != nil {
+		return err
+	}
+
+	for _, extra := range extras {
+		if err := copyFile(fs, s, extra, extraDstPath(dstPath, e, extra), false, bar, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Extras", func() {
+	table.DescribeTable("IsExtra",
+		func(name string, extra bool) {
+			Expect(IsExtra(name, "Show - S01E01")).To(Equal(extra))
+		},
+		table.Entry("a subtitle", "Show - S01E01.srt", true),
+		table.Entry("a subtitle with a language", "Show - S01E01.en.forced.srt", true),
+		table.Entry("an upper case extension", "Show - S01E01.EN.SRT", true),
+		table.Entry("an nfo", "Show - S01E01.nfo", true),
+		table.Entry("a thumbnail", "Show - S01E01-thumb.jpg", true),
+		table.Entry("the episode file itself", "Show - S01E01.mkv", false),
+		table.Entry("the stem alone", "Show - S01E01", false),
+		table.Entry("a file of another episode", "Show - S01E01-S01E02.srt", false),
+		table.Entry("a file of a later episode", "Show - S01E010.srt", false),
+		table.Entry("a file of another series", "Other Show - S01E01.srt", false),
+		table.Entry("a thumbnail after a dot", "Show - S01E01.thumb.jpg", false),
+		table.Entry("an image that is not a thumbnail", "Show - S01E01.jpg", false),
+	)
+
+	Describe("ExtraDstPath", func() {
+		It("Should name the extra after the saved episode file", func() {
+			dst := filepath.Join("downloads", "Westworld S01E01.mkv")
+			Expect(ExtraDstPath(dst, "/tv/Westworld/Show - S01E01.mkv", "/tv/Westworld/Show - S01E01.en.srt")).
+				To(Equal(filepath.Join("downloads", "Westworld S01E01.en.srt")))
+			Expect(ExtraDstPath(dst, "/tv/Westworld/Show - S01E01.mkv", "/tv/Westworld/Show - S01E01-thumb.jpg")).
+				To(Equal(filepath.Join("downloads", "Westworld S01E01-thumb.jpg")))
+		})
+	})
+})
@@ -1,43 +1,115 @@
 package cmd
 
 import (
+	"bytes"
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strconv"
 
 	"github.com/lgug2z/sgrab/sonarr"
-	"github.com/pkg/sftp"
 	pb "gopkg.in/cheggaaa/pb.v1"
 
-	"bufio"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"os"
-	"path/filepath"
+	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func hasRequiredFlags(f Flags) bool {
+	// A dry run never connects to the seedbox
+	return hasSonarrFlags(f) &&
+		(hasSeedboxFlags(f) || f.DryRun) &&
+		hasSeriesSelector(f) &&
+		hasEpisodeSelector(f)
+}
+
+func hasEpisodeSelector(f Flags) bool {
+	return len(f.Episode) > 0 ||
+		len(f.Absolute) > 0 ||
+		len(f.DateRange) > 0 ||
+		f.Latest > 0
+}
+
+func hasSonarrFlags(f Flags) bool {
+	return len(f.SonarrURL) > 0 &&
+		len(f.APIKey) > 0
+}
+
+func hasSeriesSelector(f Flags) bool {
+	return len(f.Series) > 0 ||
+		f.SeriesID > 0 ||
+		f.TvdbID > 0 ||
+		len(f.ImdbID) > 0
+}
+
+func hasSeedboxFlags(f Flags) bool {
 	return len(f.SeedboxURL) > 0 &&
-		len(f.SonarrURL) > 0 &&
-		len(f.APIKey) > 0 &&
-		len(f.Username) > 0 &&
-		len(f.Series) > 0 &&
-		len(f.Episode) > 0
+		len(f.Username) > 0
 }
 
+const (
+	AuthPublicKey           = "publickey"
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
+)
+
+var defaultAuthMethods = []string{AuthPublicKey, AuthPassword, AuthKeyboardInteractive}
+
 type Flags struct {
-	APIKey         string
-	Episode        string
-	SSHKeyLocation string
-	SeedboxURL     string
-	Series         string
-	SonarrURL      string
-	Username       string
-	Port           string
+	APIKey                  string
+	Episode                 string
+	Absolute                string
+	DateRange               string
+	Latest                  int
+	SSHKeyLocation          string
+	SSHKeyPassphrase        string
+	SSHKeyPassphraseCommand string
+	Password                string
+	PasswordCommand         string
+	AuthMethods             []string
+	SSHConfig               string
+	Jump                    string
+	Proxy                   string
+	Retries                 int
+	PathMappings            []string
+	WithExtras              bool
+	Jobs                    int
+	Output                  string
+	DryRun                  bool
+	Verbose                 int
+	LogFile                 string
+	LedgerFile              string
+	Plex                    string
+	PlexToken               string
+	PlexSection             string
+	Jellyfin                string
+	JellyfinAPIKey          string
+	MediaPathMappings       []string
+	Refresh                 bool
+	CacheTTL                time.Duration
+	KeepAlive               time.Duration
+	KnownHosts              string
+	HostKeyFingerprint      string
+	SeedboxURL              string
+	Series                  string
+	SeriesID                int
+	TvdbID                  int
+	ImdbID                  string
+	SonarrURL               string
+	Username                string
+	Port                    string
 }
 
 func urlWithSlash(url string) string {
@@ -48,13 +120,53 @@ func urlWithSlash(url string) string {
 	return url
 }
 
-func getKeyFile(location string) (key ssh.Signer, err error) {
-	buf, err := ioutil.ReadFile(location)
+// readSecret returns a secret from the first available source: the value
+// itself (usually set from the environment), the output of a command, or a
+// prompt on the terminal with echo disabled.
+func readSecret(value, command, prompt string) ([]byte, error) {
+	if len(value) > 0 {
+		return []byte(value), nil
+	}
+
+	if len(command) > 0 {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return nil, fmt.Errorf("error running %q: %v", command, err)
+		}
+
+		return []byte(strings.TrimRight(string(out), "\r\n")), nil
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, ErrNoTerminal
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err = ssh.ParsePrivateKey(buf)
+	return secret, nil
+}
+
+// getKeyFile returns the key file on disk. An encrypted key is returned still
+// encrypted, see encryptedKey.
+func getKeyFile(f Flags) (ssh.Signer, error) {
+	buf, err := ioutil.ReadFile(f.SSHKeyLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ssh.ParsePrivateKey(buf)
+	if missing, ok := err.(*ssh.PassphraseMissingError); ok {
+		k := &encryptedKey{f: f, buf: buf, pub: missing.PublicKey}
+		if k.pub == nil {
+			k.pub = readPublicKey(f.SSHKeyLocation + ".pub")
+		}
+		return k, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -62,76 +174,247 @@ func getKeyFile(location string) (key ssh.Signer, err error) {
 	return key, nil
 }
 
-// From https://stackoverflow.com/questions/45441735/ssh-handshake-complains-about-missing-host-key
-func getHostKey(fs afero.Fs, host string) (ssh.PublicKey, error) {
-	file, err := fs.Open(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
+// readPublicKey returns the public key in the authorized_keys format file at
+// path, or nil if it cannot be read.
+func readPublicKey(path string) ssh.PublicKey {
+	buf, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var hostKey ssh.PublicKey
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(buf)
+	if err != nil {
+		return nil
+	}
 
-	for scanner.Scan() {
-		fields := strings.Split(scanner.Text(), " ")
-		if len(fields) != 3 {
-			continue
+	return pub
+}
+
+// encryptedKey is a key file that needs a passphrase. If its public key is
+// known, the passphrase is only asked for once the seedbox has accepted the
+// key and a signature is needed.
+type encryptedKey struct {
+	f    Flags
+	buf  []byte
+	pub  ssh.PublicKey
+	once sync.Once
+	key  ssh.Signer
+	err  error
+}
+
+func (k *encryptedKey) decrypt() (ssh.Signer, error) {
+	k.once.Do(func() {
+		var passphrase []byte
+		passphrase, k.err = readSecret(
+			k.f.SSHKeyPassphrase,
+			k.f.SSHKeyPassphraseCommand,
+			fmt.Sprintf("Enter passphrase for key '%s': ", k.f.SSHKeyLocation),
+		)
+		if k.err != nil {
+			return
 		}
-		if strings.Contains(fields[0], host) {
-			var err error
-			hostKey, _, _, _, err = ssh.ParseAuthorizedKey(scanner.Bytes())
-			if err != nil {
-				return nil, fmt.Errorf("error parsing %q: %v", fields[2], err)
-			}
-			break
+
+		k.key, k.err = ssh.ParsePrivateKeyWithPassphrase(k.buf, passphrase)
+		if k.err == x509.IncorrectPasswordError {
+			k.err = ErrIncorrectPassphrase
 		}
-	}
+	})
+
+	return k.key, k.err
+}
+
+func (k *encryptedKey) PublicKey() ssh.PublicKey {
+	return k.pub
+}
 
-	if hostKey == nil {
-		return nil, fmt.Errorf("no hostkey for %s", host)
+func (k *encryptedKey) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	key, err := k.decrypt()
+	if err != nil {
+		return nil, err
 	}
 
-	return hostKey, nil
+	return key.Sign(rand, data)
 }
 
-func copyFile(fs afero.Fs, f Flags, k ssh.Signer, e, dstPath string) error {
-	// Make sure there is an entry for the seedbox in $HOME/.ssh/known_hosts before connecting
-	// Comment out when running test with Vagrant
-	hostKey, err := getHostKey(fs, f.SeedboxURL)
+func (k *encryptedKey) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	key, err := k.decrypt()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	as, ok := key.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("ssh: key does not support signing with %s", algorithm)
 	}
 
-	sshConfig := &ssh.ClientConfig{
-		User: f.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(k),
-		},
-		//HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Use this when running tests with Vagrant
-		HostKeyCallback: ssh.FixedHostKey(hostKey),
+	return as.SignWithAlgorithm(rand, data, algorithm)
+}
+
+// getAgentSigners connects to the ssh-agent listening on $SSH_AUTH_SOCK, if
+// there is one, and returns the keys it holds.
+func getAgentSigners() ([]ssh.Signer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if len(socket) == 0 {
+		return nil, ErrNoSSHAgent
 	}
 
-	// Make an SSH connection
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", f.SeedboxURL, f.Port), sshConfig)
+	conn, err := net.Dial("unix", socket)
 	if err != nil {
-		return ErrCredentialsRejected
+		return nil, err
 	}
-	defer client.Close()
 
-	// Open an SFTP session over the SSH connection
-	sftp, err := sftp.NewClient(client)
+	return agent.NewClient(conn).Signers()
+}
+
+// getSigners returns the keys from the ssh-agent followed by the key file on
+// disk, unless the agent already holds it. The passphrase of an encrypted key
+// file is not asked for up front if its public key is known; if it is not, the
+// key file is only used when the agent holds no keys. An error is only
+// returned if neither could be used.
+func getSigners(f Flags) ([]ssh.Signer, error) {
+	signers, _ := getAgentSigners()
+
+	k, err := getKeyFile(f)
+	if err != nil {
+		if len(signers) == 0 {
+			return nil, err
+		}
+		return signers, nil
+	}
+
+	if encrypted, ok := k.(*encryptedKey); ok && encrypted.pub == nil {
+		if len(signers) > 0 {
+			logger.Debug("skipping encrypted key file", "key", f.SSHKeyLocation, "agent_keys", len(signers))
+			return signers, nil
+		}
+
+		if k, err = encrypted.decrypt(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range signers {
+		if bytes.Equal(s.PublicKey().Marshal(), k.PublicKey().Marshal()) {
+			return signers, nil
+		}
+	}
+
+	return append(signers, k), nil
+}
+
+// passwordSource fetches the seedbox password at most once, and only when the
+// seedbox actually asks for it.
+type passwordSource struct {
+	f        Flags
+	once     sync.Once
+	password string
+	err      error
+}
+
+func (p *passwordSource) Password() (string, error) {
+	p.once.Do(func() {
+		var secret []byte
+		secret, p.err = readSecret(
+			p.f.Password,
+			p.f.PasswordCommand,
+			fmt.Sprintf("%s@%s's password: ", p.f.Username, p.f.SeedboxURL),
+		)
+		p.password = string(secret)
+	})
+
+	return p.password, p.err
+}
+
+// Challenge answers every keyboard-interactive question with the password,
+// which is what seedbox PAM configurations ask for.
+func (p *passwordSource) Challenge(user, instruction string, questions []string, echos []bool) ([]string, error) {
+	answers := make([]string, len(questions))
+	for i := range questions {
+		password, err := p.Password()
+		if err != nil {
+			return nil, err
+		}
+		answers[i] = password
+	}
+
+	return answers, nil
+}
+
+// getAuthMethods returns the auth methods to offer the seedbox in the order
+// given by the --auth flag. Methods the seedbox does not support are skipped
+// during the handshake.
+func getAuthMethods(f Flags) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	var keyErr error
+	p := &passwordSource{f: f}
+
+	order := f.AuthMethods
+	if len(order) == 0 {
+		order = defaultAuthMethods
+	}
+
+	for _, method := range order {
+		switch method {
+		case AuthPublicKey:
+			signers, err := getSigners(f)
+			if err != nil {
+				logger.Debug("skipping auth method", "method", method, "host", f.SeedboxURL, "error", err)
+				keyErr = err
+				continue
+			}
+			methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+				logger.Info("trying auth method", "method", AuthPublicKey, "host", f.SeedboxURL, "user", f.Username, "keys", len(signers))
+				return signers, nil
+			}))
+		case AuthPassword:
+			methods = append(methods, ssh.PasswordCallback(func() (string, error) {
+				logger.Info("trying auth method", "method", AuthPassword, "host", f.SeedboxURL, "user", f.Username)
+				return p.Password()
+			}))
+		case AuthKeyboardInteractive:
+			methods = append(methods, ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				logger.Info("trying auth method", "method", AuthKeyboardInteractive, "host", f.SeedboxURL, "user", f.Username, "questions", len(questions))
+				return p.Challenge(user, instruction, questions, echos)
+			}))
+		default:
+			return nil, ErrUnknownAuthMethod(method)
+		}
+	}
+
+	if len(methods) == 0 {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return nil, ErrInformationMissing
+	}
+
+	return methods, nil
+}
+
+// copyFile copies the file at e on the seedbox to dstPath, reporting progress
+// on bar and, if it is not nil, total. If resume is set, a partial file
+// already at dstPath is carried on from rather than replaced, and is kept if
+// the transfer fails.
+func copyFile(fs afero.Fs, s *session, e, dstPath string, resume bool, bar *pb.ProgressBar, total io.Writer) error {
+	// Get the SFTP client for the run's shared SSH connection
+	sftp, err := s.SFTP()
 	if err != nil {
 		return err
 	}
-	defer sftp.Close()
 
 	// Open the episode file on the seedbox
 	src, err := sftp.Open(e)
+	if os.IsNotExist(err) {
+		return ErrEpisodeFileNotFound(e)
+	}
 	if err != nil {
 		return err
 	}
-	defer src.Close()
+	defer func() {
+		if src != nil {
+			src.Close()
+		}
+	}()
 
 	// Get the episode file info
 	fi, err := src.Stat()
@@ -139,47 +422,170 @@ func copyFile(fs afero.Fs, f Flags, k ssh.Signer, e, dstPath string) error {
 		return err
 	}
 
-	// Create the local file to copy to
-	dst, err := fs.Create(dstPath)
+	// Create the local file to copy to, or carry on from the end of a partial
+	// one when resuming
+	var written int64
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		if partial, err := fs.Stat(dstPath); err == nil && partial.Size() <= fi.Size() {
+			written = partial.Size()
+			flag = os.O_WRONLY | os.O_APPEND
+		}
+	}
+
+	dst, err := fs.OpenFile(dstPath, flag, 0666)
 	if err != nil {
 		return err
 	}
 	defer dst.Close()
 
-	// Start a status bar based on the episode file size
-	fmt.Println(fi.Name())
-	bar := pb.New64(fi.Size()).SetUnits(pb.U_BYTES)
-	bar.ShowSpeed = true
-	bar.Start()
-	defer bar.Finish()
+	if _, err := src.Seek(written, io.SeekStart); err != nil {
+		return err
+	}
 
-	// Create a MultiWriter to write to the local file and send info to the progress bar
-	dstWriter := io.MultiWriter(dst, bar)
+	logger.Info("transfer started", "remote", e, "path", dstPath, "size", fi.Size(), "offset", written)
+	start := time.Now()
 
-	// Copy the file
-	_, err = io.Copy(dstWriter, src)
-	if err != nil {
-		return err
+	// Reset the status bar to the episode file size
+	name := fmt.Sprintf("%s ", fi.Name())
+	bar.SetTotal64(fi.Size())
+	bar.Set64(written)
+	bar.Prefix(name)
+
+	if total == nil {
+		total = ioutil.Discard
 	}
 
-	return nil
+	// Create a MultiWriter to write to the local file and send info to the progress bars
+	local := &errWriter{w: dst}
+	dstWriter := io.MultiWriter(local, bar, total)
+
+	// Copy the file, reconnecting and resuming from the current offset if the
+	// connection drops part way through
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			bar.Prefix(fmt.Sprintf("%s(retry %d/%d) ", name, attempt, s.f.Retries))
+			emit(s.f, event{Event: EventRetry, Remote: e, Path: dstPath, Attempt: attempt, Transferred: written})
+			time.Sleep(backoff(attempt))
+
+			if src != nil {
+				src.Close()
+			}
+
+			sftp, src, err = s.OpenAt(e, written)
+			if err != nil {
+				logger.Info("reopening episode file failed", "remote", e, "attempt", attempt, "error", err)
+				if attempt >= s.f.Retries {
+					dst.Close()
+					if !resume {
+						fs.Remove(dstPath)
+					}
+					return err
+				}
+				continue
+			}
+		}
+
+		n, err := io.Copy(dstWriter, src)
+		written += n
+
+		if err == nil {
+			logger.Info("transfer finished", "remote", e, "path", dstPath, "bytes", written, "duration", time.Since(start))
+			return nil
+		}
+
+		logger.Info("transfer interrupted", "remote", e, "path", dstPath, "bytes", written, "attempt", attempt, "error", err)
+
+		// Errors writing the local file will not be fixed by reconnecting
+		if local.err != nil || attempt >= s.f.Retries {
+			dst.Close()
+			if !resume {
+				fs.Remove(dstPath)
+			}
+			return err
+		}
+
+		s.Reset(sftp)
+	}
 }
 
-func findEpisodeFileID(episodes []sonarr.Episode, toFind string) (int, error) {
-	toFind = strings.ToLower(toFind)
+// backoff returns how long to wait before the given retry attempt, doubling
+// from one second up to a maximum of thirty seconds.
+func backoff(attempt int) time.Duration {
+	wait := time.Second << uint(attempt-1)
+	if wait > 30*time.Second || wait <= 0 {
+		return 30 * time.Second
+	}
+
+	return wait
+}
+
+// errWriter records the first error returned by the underlying writer, so
+// that write errors can be told apart from read errors after an io.Copy.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+
+	return n, err
+}
+
+// parseEpisode parses an episode in the format "s01e02".
+func parseEpisode(toFind string) (season, episode int, err error) {
+	lower := strings.ToLower(toFind)
 
 	seasonRegex := regexp.MustCompile(`s\d{2}`)
-	s0x := seasonRegex.FindString(toFind)
+	s0x := seasonRegex.FindString(lower)
 
 	episodeRegex := regexp.MustCompile(`e\d{2}`)
-	e0x := episodeRegex.FindString(toFind)
+	e0x := episodeRegex.FindString(lower)
 
-	season, err := strconv.Atoi(strings.TrimPrefix(s0x, "s"))
+	season, err = strconv.Atoi(strings.TrimPrefix(s0x, "s"))
 	if err != nil {
-		return 0, err
+		return 0, 0, ErrInvalidEpisode(toFind)
+	}
+
+	episode, err = strconv.Atoi(strings.TrimPrefix(e0x, "e"))
+	if err != nil {
+		return 0, 0, ErrInvalidEpisode(toFind)
+	}
+
+	return season, episode, nil
+}
+
+// episodesInRange returns the episodes numbered from "s01e03" to "s01e05"
+// inclusive, whether or not they have a file.
+func episodesInRange(episodes []sonarr.Episode, from, to string) ([]sonarr.Episode, error) {
+	fromSeason, fromEpisode, err := parseEpisode(from)
+	if err != nil {
+		return nil, err
 	}
 
-	episode, err := strconv.Atoi(strings.TrimPrefix(e0x, "e"))
+	toSeason, toEpisode, err := parseEpisode(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []sonarr.Episode
+	for _, e := range episodes {
+		afterFrom := e.SeasonNumber > fromSeason || (e.SeasonNumber == fromSeason && e.EpisodeNumber >= fromEpisode)
+		beforeTo := e.SeasonNumber < toSeason || (e.SeasonNumber == toSeason && e.EpisodeNumber <= toEpisode)
+		if afterFrom && beforeTo {
+			matched = append(matched, e)
+		}
+	}
+
+	return matched, nil
+}
+
+func findEpisodeFileID(episodes []sonarr.Episode, toFind string) (int, error) {
+	season, episode, err := parseEpisode(toFind)
 	if err != nil {
 		return 0, err
 	}
@@ -194,6 +600,78 @@ func findEpisodeFileID(episodes []sonarr.Episode, toFind string) (int, error) {
 
 }
 
+// findEpisodeFileIDs returns the episode file IDs for a comma separated list
+// of episodes, ranges of episodes, absolute episode numbers and air dates,
+// e.g. "s01e01,s01e03-s01e05,150-160,2024-03-15". Episodes within a range, with
+// an absolute number or aired on a date that have no file are skipped.
+func findEpisodeFileIDs(episodes []sonarr.Episode, toFind string) ([]int, error) {
+	sorted := make([]sonarr.Episode, len(episodes))
+	copy(sorted, episodes)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].SeasonNumber != sorted[j].SeasonNumber {
+			return sorted[i].SeasonNumber < sorted[j].SeasonNumber
+		}
+		return sorted[i].EpisodeNumber < sorted[j].EpisodeNumber
+	})
+
+	var ids []int
+	for _, part := range strings.Split(toFind, ",") {
+		if isAirDate(part) {
+			ids = append(ids, findEpisodeFileIDsByAirDate(sorted, part, part)...)
+			continue
+		}
+
+		if isAbsolute(part) {
+			from, to, err := parseAbsolute(part)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, findEpisodeFileIDsByAbsolute(sorted, from, to)...)
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) == 1 {
+			id, err := findEpisodeFileID(sorted, part)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+			continue
+		}
+
+		matched, err := episodesInRange(sorted, bounds[0], bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range matched {
+			if e.EpisodeFileID != 0 {
+				ids = append(ids, e.EpisodeFileID)
+			}
+		}
+	}
+
+	return uniqueIDs(ids), nil
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first of each. Files
+// holding several episodes, e.g. double episodes, are found once per episode
+// but must only be grabbed once.
+func uniqueIDs(ids []int) []int {
+	seen := map[int]bool{}
+
+	var unique []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
 func findSeries(series []sonarr.Series, toFind string) (sonarr.Series, error) {
 	for _, s := range series {
 		if strings.ToLower(s.Title) == strings.ToLower(toFind) {
@@ -203,3 +681,51 @@ func findSeries(series []sonarr.Series, toFind string) (sonarr.Series, error) {
 
 	return sonarr.Series{}, ErrCouldNotFindSeries(toFind)
 }
+
+// findRequestedSeries looks up the series chosen with --series, --series-id,
+// --tvdb-id or --imdb-id. A Sonarr ID is looked up directly rather than by
+// fetching every series.
+func findRequestedSeries(f Flags, c sonarr.SonarrClient) (sonarr.Series, error) {
+	selectors := 0
+	for _, set := range []bool{len(f.Series) > 0, f.SeriesID > 0, f.TvdbID > 0, len(f.ImdbID) > 0} {
+		if set {
+			selectors++
+		}
+	}
+
+	if selectors > 1 {
+		return sonarr.Series{}, ErrMultipleSeriesSelectors
+	}
+
+	if f.SeriesID > 0 {
+		s, err := c.SeriesByID(f.SeriesID)
+		if errors.Is(err, sonarr.ErrNotFound) {
+			return s, ErrCouldNotFindSeries(strconv.Itoa(f.SeriesID))
+		}
+		return s, err
+	}
+
+	series, err := c.Series()
+	if err != nil {
+		return sonarr.Series{}, err
+	}
+
+	switch {
+	case f.TvdbID > 0:
+		for _, s := range series {
+			if s.TvdbID == f.TvdbID {
+				return s, nil
+			}
+		}
+		return sonarr.Series{}, ErrCouldNotFindSeries(fmt.Sprintf("tvdb:%d", f.TvdbID))
+	case len(f.ImdbID) > 0:
+		for _, s := range series {
+			if strings.EqualFold(s.ImdbID, f.ImdbID) {
+				return s, nil
+			}
+		}
+		return sonarr.Series{}, ErrCouldNotFindSeries(fmt.Sprintf("imdb:%s", f.ImdbID))
+	}
+
+	return findSeries(series, f.Series)
+}
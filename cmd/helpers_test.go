@@ -0,0 +1,33 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FindEpisodeFileIDs", func() {
+	// s01e01 and s01e02 are a double episode sharing a single file
+	episodes := []sonarr.Episode{
+		{ID: 1, SeasonNumber: 1, EpisodeNumber: 1, EpisodeFileID: 10, AbsoluteEpisodeNumber: 1, AirDate: "2016-10-02"},
+		{ID: 2, SeasonNumber: 1, EpisodeNumber: 2, EpisodeFileID: 10, AbsoluteEpisodeNumber: 2, AirDate: "2016-10-02"},
+		{ID: 3, SeasonNumber: 1, EpisodeNumber: 3, EpisodeFileID: 11, AbsoluteEpisodeNumber: 3, AirDate: "2016-10-09"},
+	}
+
+	Describe("When a file holds several episodes", func() {
+		It("Should return it once for a range", func() {
+			Expect(FindEpisodeFileIDs(episodes, "s01e01-s01e03")).To(Equal([]int{10, 11}))
+		})
+
+		It("Should return it once for a list", func() {
+			Expect(FindEpisodeFileIDs(episodes, "s01e01,s01e02,s01e01")).To(Equal([]int{10}))
+		})
+
+		It("Should return it once for absolute numbers and air dates", func() {
+			Expect(FindEpisodeFileIDs(episodes, "1-3")).To(Equal([]int{10, 11}))
+			Expect(FindEpisodeFileIDs(episodes, "2016-10-02")).To(Equal([]int{10}))
+		})
+	})
+})
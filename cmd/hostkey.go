@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const globalKnownHosts = "/etc/ssh/ssh_known_hosts"
+
+// getHostKeyCallback returns a callback that verifies host keys against the
+// given known_hosts files, skipping any that do not exist. Hashed entries,
+// [host]:port entries, @cert-authority and @revoked markers are all handled
+// by knownhosts.
+func getHostKeyCallback(files ...string) (ssh.HostKeyCallback, error) {
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+
+	if len(existing) == 0 {
+		return func(string, net.Addr, ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	}
+
+	return knownhosts.New(existing...)
+}
+
+// checkHostKey wraps a known_hosts callback so that its errors are reported
+// using the errors in errors.go.
+func checkHostKey(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return ErrNoHostKey(knownhosts.Normalize(hostname))
+			}
+			return ErrHostKeyMismatch(knownhosts.Normalize(hostname), keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+
+		var revokedErr *knownhosts.RevokedError
+		if errors.As(err, &revokedErr) {
+			return ErrHostKeyRevoked(knownhosts.Normalize(hostname))
+		}
+
+		return err
+	}
+}
+
+// trustOnFirstUse wraps a known_hosts callback so that the key of a host with
+// no known_hosts entry is accepted if it matches the pinned fingerprint, or if
+// there is no pinned fingerprint and the user confirms it. Accepted keys are
+// appended to file. Keys that conflict with an existing entry are never
+// accepted.
+func trustOnFirstUse(cb ssh.HostKeyCallback, file, fingerprint string) ssh.HostKeyCallback {
+	if len(fingerprint) > 0 && !strings.HasPrefix(fingerprint, "SHA256:") {
+		fingerprint = fmt.Sprintf("SHA256:%s", fingerprint)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if len(fingerprint) > 0 && ssh.FingerprintSHA256(key) != fingerprint {
+			return ErrHostKeyFingerprintMismatch(knownhosts.Normalize(hostname), ssh.FingerprintSHA256(key))
+		}
+
+		err := cb(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return err
+		}
+
+		if len(fingerprint) == 0 && !confirmHostKey(hostname, key) {
+			return err
+		}
+
+		return addKnownHost(file, hostname, key)
+	}
+}
+
+// confirmHostKey asks the user on the terminal whether to trust key for
+// hostname, in the same way as OpenSSH does.
+func confirmHostKey(hostname string, key ssh.PublicKey) bool {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", knownhosts.Normalize(hostname))
+	fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+	fmt.Fprint(os.Stderr, "Are you sure you want to continue connecting (yes/no)? ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return strings.ToLower(strings.TrimSpace(answer)) == "yes"
+}
+
+// addKnownHost appends an entry for hostname to the known_hosts file,
+// creating the file and its directory if needed.
+func addKnownHost(file, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{hostname}, key))
+	return err
+}
+
+// hostKeyAlgorithms returns the host key algorithms matching the keys already
+// known for addr, so that the seedbox presents a key we are able to verify
+// rather than its preferred one. Nil is returned if no keys are known.
+func hostKeyAlgorithms(cb ssh.HostKeyCallback, addr string) []string {
+	// A key that can never match makes the callback list every known key
+	var keyErr *knownhosts.KeyError
+	if !errors.As(cb(addr, &net.TCPAddr{}, unmatchableKey{}), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, known := range keyErr.Want {
+		for _, algorithm := range algorithmsForKeyType(known.Key.Type()) {
+			if !seen[algorithm] {
+				seen[algorithm] = true
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+
+	return algorithms
+}
+
+func algorithmsForKeyType(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+
+	return []string{keyType}
+}
+
+type unmatchableKey struct{}
+
+func (unmatchableKey) Type() string    { return "unmatchable" }
+func (unmatchableKey) Marshal() []byte { return nil }
+func (unmatchableKey) Verify(data []byte, sig *ssh.Signature) error {
+	return fmt.Errorf("unmatchable key")
+}
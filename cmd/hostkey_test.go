@@ -0,0 +1,207 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newHostKey generates an ed25519 host key.
+func newHostKey() ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	key, err := ssh.NewPublicKey(pub)
+	Expect(err).NotTo(HaveOccurred())
+	return key
+}
+
+var remote = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+var _ = Describe("Host keys", func() {
+	var (
+		dir        string
+		knownHosts string
+		key        ssh.PublicKey
+	)
+
+	// writeKnownHosts writes the given lines to the known_hosts file
+	writeKnownHosts := func(lines ...string) {
+		Expect(ioutil.WriteFile(knownHosts, []byte(strings.Join(lines, "\n")+"\n"), 0600)).To(Succeed())
+	}
+
+	check := func(hostname string, key ssh.PublicKey) error {
+		cb, err := GetHostKeyCallback(knownHosts, filepath.Join(dir, "missing"))
+		Expect(err).NotTo(HaveOccurred())
+		return CheckHostKey(cb)(hostname, remote, key)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sgrab-hostkey")
+		Expect(err).NotTo(HaveOccurred())
+
+		knownHosts = filepath.Join(dir, "known_hosts")
+		key = newHostKey()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("When checking a host key", func() {
+		It("Should accept a key with a plain entry", func() {
+			writeKnownHosts(knownhosts.Line([]string{"mybox.com"}, key))
+			Expect(check("mybox.com:22", key)).To(Succeed())
+		})
+
+		It("Should accept a key with a hashed entry", func() {
+			writeKnownHosts(knownhosts.Line([]string{knownhosts.HashHostname("mybox.com")}, key))
+			Expect(check("mybox.com:22", key)).To(Succeed())
+		})
+
+		It("Should match [host]:port entries on the port", func() {
+			writeKnownHosts(knownhosts.Line([]string{"[mybox.com]:2222"}, key))
+			Expect(check("mybox.com:2222", key)).To(Succeed())
+			Expect(check("mybox.com:22", key)).To(MatchError(ErrNoHostKey("mybox.com")))
+		})
+
+		It("Should return an error for a host without an entry", func() {
+			writeKnownHosts(knownhosts.Line([]string{"other.com"}, key))
+			Expect(check("mybox.com:22", key)).To(MatchError(ErrNoHostKey("mybox.com")))
+		})
+
+		It("Should return an error if no known_hosts file exists", func() {
+			cb, err := GetHostKeyCallback(filepath.Join(dir, "missing"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(CheckHostKey(cb)("mybox.com:22", remote, key)).To(MatchError(ErrNoHostKey("mybox.com")))
+		})
+
+		It("Should return the file and line of the entry a changed key does not match", func() {
+			writeKnownHosts(
+				knownhosts.Line([]string{"other.com"}, newHostKey()),
+				knownhosts.Line([]string{"mybox.com"}, key),
+			)
+
+			err := check("mybox.com:22", newHostKey())
+			Expect(err).To(MatchError(ErrHostKeyMismatch("mybox.com", knownHosts, 2)))
+			Expect(err.Error()).To(Equal(ErrHostKeyMismatch("mybox.com", knownHosts, 2).Error()))
+		})
+
+		It("Should reject a revoked key", func() {
+			writeKnownHosts(
+				knownhosts.Line([]string{"mybox.com"}, key),
+				"@revoked * "+strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+			)
+
+			Expect(check("mybox.com:22", key)).To(MatchError(ErrHostKeyRevoked("mybox.com")))
+		})
+	})
+
+	Describe("When trusting a host key on first use", func() {
+		// trust checks key for hostname in the same way as a connection does
+		trust := func(hostname string, key ssh.PublicKey, fingerprint string) error {
+			cb, err := GetHostKeyCallback(knownHosts)
+			Expect(err).NotTo(HaveOccurred())
+			return CheckHostKey(TrustOnFirstUse(cb, knownHosts, fingerprint))(hostname, remote, key)
+		}
+
+		known := func(hostname string, key ssh.PublicKey) error {
+			cb, err := knownhosts.New(knownHosts)
+			Expect(err).NotTo(HaveOccurred())
+			return cb(hostname, remote, key)
+		}
+
+		It("Should accept and record a key matching the pinned fingerprint", func() {
+			Expect(trust("mybox.com:2222", key, ssh.FingerprintSHA256(key))).To(Succeed())
+			Expect(known("mybox.com:2222", key)).To(Succeed())
+		})
+
+		It("Should accept a pinned fingerprint without the SHA256: prefix", func() {
+			fingerprint := strings.TrimPrefix(ssh.FingerprintSHA256(key), "SHA256:")
+			Expect(trust("mybox.com:22", key, fingerprint)).To(Succeed())
+			Expect(known("mybox.com:22", key)).To(Succeed())
+		})
+
+		It("Should reject a key not matching the pinned fingerprint", func() {
+			err := trust("mybox.com:22", key, ssh.FingerprintSHA256(newHostKey()))
+			Expect(err).To(MatchError(ErrHostKeyFingerprintMismatch("mybox.com", ssh.FingerprintSHA256(key))))
+			Expect(knownHosts).NotTo(BeAnExistingFile())
+		})
+
+		It("Should never accept a key conflicting with an existing entry", func() {
+			existing := knownhosts.Line([]string{"mybox.com"}, newHostKey())
+			writeKnownHosts(existing)
+
+			err := trust("mybox.com:22", key, ssh.FingerprintSHA256(key))
+			Expect(err).To(MatchError(ErrHostKeyMismatch("mybox.com", knownHosts, 1)))
+			Expect(ioutil.ReadFile(knownHosts)).To(Equal([]byte(existing + "\n")))
+		})
+
+		It("Should not accept a key without a pinned fingerprint or a terminal to confirm it", func() {
+			Expect(trust("mybox.com:22", key, "")).To(MatchError(ErrNoHostKey("mybox.com")))
+			Expect(knownHosts).NotTo(BeAnExistingFile())
+		})
+
+		It("Should record the host in the normalised [host]:port form", func() {
+			file := filepath.Join(dir, "ssh", "known_hosts")
+			Expect(AddKnownHost(file, "mybox.com:2222", key)).To(Succeed())
+			Expect(AddKnownHost(file, "other.com:22", key)).To(Succeed())
+
+			lines, err := ioutil.ReadFile(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Split(strings.TrimSpace(string(lines)), "\n")).To(Equal([]string{
+				knownhosts.Line([]string{"[mybox.com]:2222"}, key),
+				knownhosts.Line([]string{"other.com"}, key),
+			}))
+
+			cb, err := knownhosts.New(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cb("mybox.com:2222", remote, key)).To(Succeed())
+			Expect(cb("other.com:22", remote, key)).To(Succeed())
+		})
+	})
+
+	Describe("When choosing host key algorithms", func() {
+		algorithms := func(addr string) []string {
+			cb, err := GetHostKeyCallback(knownHosts)
+			Expect(err).NotTo(HaveOccurred())
+			return HostKeyAlgorithms(cb, addr)
+		}
+
+		It("Should only offer the types of the keys already known", func() {
+			writeKnownHosts(knownhosts.Line([]string{"mybox.com"}, key))
+			Expect(algorithms("mybox.com:22")).To(Equal([]string{ssh.KeyAlgoED25519}))
+		})
+
+		It("Should offer every signature algorithm for an RSA key", func() {
+			private, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			rsaKey, err := ssh.NewPublicKey(&private.PublicKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			writeKnownHosts(
+				knownhosts.Line([]string{"mybox.com"}, key),
+				knownhosts.Line([]string{"mybox.com"}, rsaKey),
+			)
+			Expect(algorithms("mybox.com:22")).To(Equal([]string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}))
+		})
+
+		It("Should leave the choice to the seedbox if no keys are known", func() {
+			writeKnownHosts(knownhosts.Line([]string{"other.com"}, key))
+			Expect(algorithms("mybox.com:22")).To(BeNil())
+		})
+	})
+})
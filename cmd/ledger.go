@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// ledger records, for each series, the episodes that have been watched or
+// grabbed, so that 'sgrab next' knows where to carry on from. Episodes are
+// recorded by their Sonarr ID, which does not change when the file does.
+type ledger struct {
+	Series map[string]*ledgerSeries `json:"series"`
+}
+
+type ledgerSeries struct {
+	Title   string `json:"title"`
+	Watched []int  `json:"watched,omitempty"`
+	Grabbed []int  `json:"grabbed,omitempty"`
+}
+
+func loadLedger(fs afero.Fs, path string) (*ledger, error) {
+	l := &ledger{Series: map[string]*ledgerSeries{}}
+
+	buf, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, l); err != nil {
+		return nil, err
+	}
+
+	if l.Series == nil {
+		l.Series = map[string]*ledgerSeries{}
+	}
+
+	return l, nil
+}
+
+func (l *ledger) Save(fs afero.Fs, path string) error {
+	buf, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, path, buf, 0600)
+}
+
+func (l *ledger) series(id int, title string) *ledgerSeries {
+	key := strconv.Itoa(id)
+	if l.Series[key] == nil {
+		l.Series[key] = &ledgerSeries{}
+	}
+	l.Series[key].Title = title
+
+	return l.Series[key]
+}
+
+// MarkWatched records the episodes with the given IDs as watched.
+func (l *ledger) MarkWatched(seriesID int, title string, episodeIDs []int) {
+	s := l.series(seriesID, title)
+	s.Watched = addIDs(s.Watched, episodeIDs)
+}
+
+// MarkGrabbed records the episodes with the given IDs as grabbed.
+func (l *ledger) MarkGrabbed(seriesID int, title string, episodeIDs []int) {
+	s := l.series(seriesID, title)
+	s.Grabbed = addIDs(s.Grabbed, episodeIDs)
+}
+
+// Seen returns the IDs of the episodes of a series that have been watched or
+// grabbed.
+func (l *ledger) Seen(seriesID int) map[int]bool {
+	seen := map[int]bool{}
+
+	s := l.Series[strconv.Itoa(seriesID)]
+	if s == nil {
+		return seen
+	}
+
+	for _, id := range append(s.Watched, s.Grabbed...) {
+		seen[id] = true
+	}
+
+	return seen
+}
+
+func addIDs(ids, add []int) []int {
+	for _, id := range add {
+		found := false
+		for _, existing := range ids {
+			found = found || existing == id
+		}
+
+		if !found {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids
+}
+
+// recordGrabs adds the episodes of every transfer that completed to the
+// ledger. Failing to do so does not fail the grab, so errors are only logged.
+func recordGrabs(fs afero.Fs, f Flags, transfers []*transfer) {
+	l, err := loadLedger(fs, f.LedgerFile)
+	if err != nil {
+		logger.Info("reading ledger failed", "path", f.LedgerFile, "error", err)
+		return
+	}
+
+	recorded := false
+	for _, t := range transfers {
+		if t.done && t.err == nil && t.SeriesID > 0 && len(t.EpisodeIDs) > 0 {
+			l.MarkGrabbed(t.SeriesID, t.Series, t.EpisodeIDs)
+			recorded = true
+		}
+	}
+
+	if !recorded {
+		return
+	}
+
+	if err := l.Save(fs, f.LedgerFile); err != nil {
+		logger.Info("writing ledger failed", "path", f.LedgerFile, "error", err)
+	}
+}
+
+// sortByNumber returns episodes in order of season and episode number,
+// leaving out specials.
+func sortByNumber(episodes []sonarr.Episode) []sonarr.Episode {
+	var sorted []sonarr.Episode
+	for _, e := range episodes {
+		if e.SeasonNumber > 0 {
+			sorted = append(sorted, e)
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].SeasonNumber != sorted[j].SeasonNumber {
+			return sorted[i].SeasonNumber < sorted[j].SeasonNumber
+		}
+		return sorted[i].EpisodeNumber < sorted[j].EpisodeNumber
+	})
+
+	return sorted
+}
+
+// nextEpisode returns the first episode with a file after the last one that
+// has been watched or grabbed, or the first episode with a file if none have.
+func nextEpisode(series sonarr.Series, episodes []sonarr.Episode, seen map[int]bool) (sonarr.Episode, error) {
+	sorted := sortByNumber(sceneNumbered(series, episodes))
+
+	next := 0
+	for i, e := range sorted {
+		if seen[e.ID] {
+			next = i + 1
+		}
+	}
+
+	for _, e := range sorted[next:] {
+		if e.EpisodeFileID != 0 {
+			return e, nil
+		}
+	}
+
+	return sonarr.Episode{}, ErrNoNextEpisode(series.Title)
+}
+
+// matchEpisodes returns the episodes matching a comma separated list of
+// episodes and ranges of episodes, e.g. "s01e01,s01e03-s01e05", whether or
+// not they have a file.
+func matchEpisodes(episodes []sonarr.Episode, toFind string) ([]sonarr.Episode, error) {
+	var matched []sonarr.Episode
+	for _, part := range strings.Split(toFind, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) == 1 {
+			bounds = append(bounds, bounds[0])
+		}
+
+		inRange, err := episodesInRange(episodes, bounds[0], bounds[1])
+		if err != nil {
+			return nil, ErrInvalidEpisode(part)
+		}
+		matched = append(matched, inRange...)
+	}
+
+	return matched, nil
+}
+
+var nextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Grab the next episode of a series.",
+	Long: `Grab the first episode with a file after the last episode of the series
+that was marked as watched with 'sgrab watched' or grabbed with sgrab, or the
+first episode if there are none. Specials are skipped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs, f, sonarrClient, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !hasSonarrFlags(f) || (!hasSeedboxFlags(f) && !f.DryRun) || !hasSeriesSelector(f) {
+			return ErrInformationMissing
+		}
+
+		return GrabNext(fs, f, sonarrClient)
+	},
+}
+
+// GrabNext grabs the next episode of the requested series according to the
+// ledger.
+func GrabNext(fs afero.Fs, f Flags, c sonarr.SonarrClient) error {
+	series, err := findRequestedSeries(f, c)
+	if err != nil {
+		return err
+	}
+
+	emit(f, event{Event: EventSeries, Series: series.Title, SeriesID: series.ID})
+
+	episodes, err := c.Episodes(series.ID)
+	if err != nil {
+		return err
+	}
+
+	l, err := loadLedger(fs, f.LedgerFile)
+	if err != nil {
+		return err
+	}
+
+	next, err := nextEpisode(series, episodes, l.Seen(series.ID))
+	if err != nil {
+		return err
+	}
+
+	transfers, err := transfersFor(f, c, series, episodes, []int{next.EpisodeFileID})
+	if err != nil {
+		return err
+	}
+
+	if f.DryRun {
+		return printPlan(fs, f, transfers, output)
+	}
+
+	return grab(fs, f, transfers, nil)
+}
+
+var watchedCmd = &cobra.Command{
+	Use:   "watched EPISODE...",
+	Short: "Mark episodes of a series as watched.",
+	Long: `Mark episodes of the series given with --series (or --series-id, --tvdb-id
+or --imdb-id) as watched, in the format "s01e04" or "s01e01-s01e04", so that
+'sgrab next' carries on after them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs, f, sonarrClient, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !hasSonarrFlags(f) || !hasSeriesSelector(f) {
+			return ErrInformationMissing
+		}
+
+		series, err := findRequestedSeries(f, sonarrClient)
+		if err != nil {
+			return err
+		}
+
+		episodes, err := sonarrClient.Episodes(series.ID)
+		if err != nil {
+			return err
+		}
+
+		matched, err := matchEpisodes(sceneNumbered(series, episodes), strings.Join(args, ","))
+		if err != nil {
+			return err
+		}
+
+		var ids []int
+		for _, e := range matched {
+			ids = append(ids, e.ID)
+		}
+
+		l, err := loadLedger(fs, f.LedgerFile)
+		if err != nil {
+			return err
+		}
+
+		l.MarkWatched(series.ID, series.Title, ids)
+		if err := l.Save(fs, f.LedgerFile); err != nil {
+			return err
+		}
+
+		if f.Output == OutputJSON {
+			for _, id := range ids {
+				emit(f, event{Event: EventWatched, Series: series.Title, SeriesID: series.ID, EpisodeID: id})
+			}
+			return nil
+		}
+
+		fmt.Printf("Marked %d episode(s) of %s as watched.\n", len(ids), series.Title)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(nextCmd, watchedCmd)
+}
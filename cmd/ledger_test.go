@@ -0,0 +1,140 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/spf13/afero"
+)
+
+// The special s00e01 and s01e02 have no place in the order of episodes to
+// watch: the former is skipped and the latter has not been downloaded yet.
+var ledgerEpisodes = []sonarr.Episode{
+	{ID: 100, SeriesID: 1, SeasonNumber: 0, EpisodeNumber: 1, EpisodeFileID: 100},
+	{ID: 1, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 1, EpisodeFileID: 1},
+	{ID: 2, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 2},
+	{ID: 3, SeriesID: 1, SeasonNumber: 1, EpisodeNumber: 3, EpisodeFileID: 3},
+	{ID: 4, SeriesID: 1, SeasonNumber: 2, EpisodeNumber: 1, EpisodeFileID: 4},
+}
+
+var _ = Describe("NextEpisode", func() {
+	series := sonarr.Series{Title: "Westworld", ID: 1}
+
+	It("Should return the first episode if none have been seen", func() {
+		Expect(NextEpisode(series, ledgerEpisodes, map[int]bool{})).To(Equal(ledgerEpisodes[1]))
+	})
+
+	It("Should return the episode after the last one seen", func() {
+		Expect(NextEpisode(series, ledgerEpisodes, map[int]bool{1: true, 3: true})).To(Equal(ledgerEpisodes[4]))
+	})
+
+	It("Should skip episodes without a file", func() {
+		Expect(NextEpisode(series, ledgerEpisodes, map[int]bool{1: true})).To(Equal(ledgerEpisodes[3]))
+	})
+
+	It("Should skip specials", func() {
+		Expect(NextEpisode(series, ledgerEpisodes, map[int]bool{100: true})).To(Equal(ledgerEpisodes[1]))
+	})
+
+	It("Should return an error after the last episode", func() {
+		_, err := NextEpisode(series, ledgerEpisodes, map[int]bool{4: true})
+		Expect(err).To(MatchError(ErrNoNextEpisode("Westworld")))
+	})
+})
+
+var _ = Describe("MatchEpisodes", func() {
+	It("Should match episodes and ranges whether or not they have a file", func() {
+		matched, err := MatchEpisodes(ledgerEpisodes, "s00e01,s01e01-s01e03")
+		Expect(err).NotTo(HaveOccurred())
+
+		var ids []int
+		for _, e := range matched {
+			ids = append(ids, e.ID)
+		}
+		Expect(ids).To(Equal([]int{100, 1, 2, 3}))
+	})
+
+	It("Should return an error for an invalid episode", func() {
+		_, err := MatchEpisodes(ledgerEpisodes, "s01e01-tomorrow")
+		Expect(err).To(MatchError(ErrInvalidEpisode("s01e01-tomorrow")))
+	})
+})
+
+var _ = Describe("GrabNext", func() {
+	var (
+		server  *ghttp.Server
+		client  sonarr.Client
+		fs      afero.Fs
+		out     *bytes.Buffer
+		restore func()
+	)
+
+	f := Flags{APIKey: "key", DryRun: true, LedgerFile: "/ledger.json", Output: OutputJSON, Series: "Westworld", SonarrURL: "http://sonarr/"}
+
+	// next returns the episode file in the plan written by a dry run
+	next := func() int {
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var e struct {
+				Event         string `json:"event"`
+				EpisodeFileID int    `json:"episodeFileId"`
+			}
+			Expect(json.Unmarshal([]byte(line), &e)).To(Succeed())
+			if e.Event == EventPlan {
+				return e.EpisodeFileID
+			}
+		}
+		return 0
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/api/series/", ghttp.RespondWithJSONEncoded(http.StatusOK, []sonarr.Series{{Title: "Westworld", ID: 1}}))
+		server.RouteToHandler("GET", "/api/episode/", ghttp.RespondWithJSONEncoded(http.StatusOK, ledgerEpisodes))
+		server.RouteToHandler("GET", "/api/episodeFile/1", ghttp.RespondWithJSONEncoded(http.StatusOK, sonarr.EpisodeFile{ID: 1, Path: "/tv/S01E01.mkv"}))
+		server.RouteToHandler("GET", "/api/episodeFile/3", ghttp.RespondWithJSONEncoded(http.StatusOK, sonarr.EpisodeFile{ID: 3, Path: "/tv/S01E03.mkv"}))
+
+		client = sonarr.Client{URL: server.URL(), APIKey: "key", Client: http.Client{}}
+		fs = afero.NewMemMapFs()
+		out = &bytes.Buffer{}
+		restore = SetOutput(out)
+	})
+
+	AfterEach(func() {
+		restore()
+		server.Close()
+	})
+
+	Describe("When the ledger is empty", func() {
+		It("Should grab the first episode", func() {
+			Expect(GrabNext(fs, f, client)).To(Succeed())
+			Expect(next()).To(Equal(1))
+		})
+	})
+
+	Describe("When episodes have been watched", func() {
+		It("Should grab the next one with a file", func() {
+			ledger := `{"series":{"1":{"title":"Westworld","watched":[1]}}}`
+			Expect(afero.WriteFile(fs, "/ledger.json", []byte(ledger), 0600)).To(Succeed())
+
+			Expect(GrabNext(fs, f, client)).To(Succeed())
+			Expect(next()).To(Equal(3))
+		})
+	})
+
+	Describe("When the last episode has been grabbed", func() {
+		It("Should return an error", func() {
+			ledger := `{"series":{"1":{"title":"Westworld","watched":[1],"grabbed":[3,4]}}}`
+			Expect(afero.WriteFile(fs, "/ledger.json", []byte(ledger), 0600)).To(Succeed())
+
+			Expect(GrabNext(fs, f, client)).To(MatchError(ErrNoNextEpisode("Westworld")))
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logger is used throughout sgrab for diagnostics. Nothing is logged unless
+// --verbose or --log-file is given.
+var logger = slog.New(slog.DiscardHandler)
+
+var logFile io.Closer
+
+// setupLogging points logger at stderr, or the file given with --log-file,
+// at the level chosen by the number of times --verbose is given.
+func setupLogging(f Flags) error {
+	if f.Verbose == 0 && len(f.LogFile) == 0 {
+		return nil
+	}
+
+	level := slog.LevelInfo
+	if f.Verbose > 1 {
+		level = slog.LevelDebug
+	}
+
+	var w io.Writer = os.Stderr
+	if len(f.LogFile) > 0 {
+		file, err := os.OpenFile(f.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		w, logFile = file, file
+	}
+
+	logger = slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+	return nil
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mediaServer is a media server, such as Plex or Jellyfin, whose library can
+// be told about new files.
+type mediaServer interface {
+	// Refresh asks the media server to scan dir for new files.
+	Refresh(dir string) error
+	String() string
+}
+
+// plex refreshes the Plex library section containing a directory, or the
+// given section if there is one.
+type plex struct {
+	URL     string
+	Token   string
+	Section string
+	client  *http.Client
+}
+
+func (p *plex) String() string {
+	return "Plex"
+}
+
+func (p *plex) Refresh(dir string) error {
+	sections := []string{p.Section}
+	if len(p.Section) == 0 {
+		var err error
+		sections, err = p.sectionsFor(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, section := range sections {
+		u := fmt.Sprintf("%s/library/sections/%s/refresh?path=%s", strings.TrimSuffix(p.URL, "/"), url.PathEscape(section), url.QueryEscape(dir))
+		if err := p.do(u, nil); err != nil {
+			return err
+		}
+
+		logger.Info("refreshed media server", "server", p, "section", section, "path", dir)
+	}
+
+	return nil
+}
+
+// sectionsFor returns the keys of the library sections with a location that
+// contains dir.
+func (p *plex) sectionsFor(dir string) ([]string, error) {
+	var res struct {
+		MediaContainer struct {
+			Directory []struct {
+				Key      string `json:"key"`
+				Location []struct {
+					Path string `json:"path"`
+				} `json:"Location"`
+			} `json:"Directory"`
+		} `json:"MediaContainer"`
+	}
+
+	if err := p.do(strings.TrimSuffix(p.URL, "/")+"/library/sections", &res); err != nil {
+		return nil, err
+	}
+
+	var sections []string
+	for _, d := range res.MediaContainer.Directory {
+		for _, l := range d.Location {
+			if within(dir, l.Path) {
+				sections = append(sections, d.Key)
+				break
+			}
+		}
+	}
+
+	if len(sections) == 0 {
+		return nil, ErrNoPlexSection(dir)
+	}
+
+	return sections, nil
+}
+
+func (p *plex) do(u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Plex-Token", p.Token)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return ErrMediaServerRefreshFailed(p.String(), res.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// jellyfin notifies Jellyfin or Emby, which share the same API, of new files
+// in a directory.
+type jellyfin struct {
+	URL    string
+	APIKey string
+	client *http.Client
+}
+
+func (j *jellyfin) String() string {
+	return "Jellyfin"
+}
+
+func (j *jellyfin) Refresh(dir string) error {
+	type update struct {
+		Path       string `json:"Path"`
+		UpdateType string `json:"UpdateType"`
+	}
+
+	body, err := json.Marshal(struct {
+		Updates []update `json:"Updates"`
+	}{[]update{{Path: dir, UpdateType: "Created"}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(j.URL, "/")+"/Library/Media/Updated", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Emby-Token", j.APIKey)
+
+	res, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return ErrMediaServerRefreshFailed(j.String(), res.Status)
+	}
+
+	logger.Info("refreshed media server", "server", j, "path", dir)
+	return nil
+}
+
+// within reports whether dir is p or inside it, matching whole path elements.
+func within(dir, p string) bool {
+	p = path.Clean(p)
+	return dir == p || strings.HasPrefix(dir, strings.TrimSuffix(p, "/")+"/")
+}
+
+// mediaServers returns the media servers to refresh after a grab. Media
+// servers are usually on the local network, so --proxy and ALL_PROXY, which
+// are meant for reaching the seedbox, are not used for them.
+func mediaServers(f Flags) []mediaServer {
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+
+	var servers []mediaServer
+	if len(f.Plex) > 0 {
+		servers = append(servers, &plex{URL: f.Plex, Token: f.PlexToken, Section: f.PlexSection, client: client})
+	}
+	if len(f.Jellyfin) > 0 {
+		servers = append(servers, &jellyfin{URL: f.Jellyfin, APIKey: f.JellyfinAPIKey, client: client})
+	}
+
+	return servers
+}
+
+// RefreshMediaServers asks every configured media server to scan the given
+// directories, mapped with --media-path-map to the paths the media server
+// sees. Every server is tried even if one fails, and the first error is
+// returned.
+func RefreshMediaServers(f Flags, dirs []string) error {
+	servers := mediaServers(f)
+	if len(servers) == 0 {
+		return nil
+	}
+
+	mappings, err := parsePathMappings(f.MediaPathMappings)
+	if err != nil {
+		return err
+	}
+
+	var refreshErr error
+	for _, server := range servers {
+		for _, dir := range dirs {
+			if err := server.Refresh(mapPath(mappings, filepath.ToSlash(dir))); err != nil {
+				logger.Info("refreshing media server failed", "server", server, "path", dir, "error", err)
+				if refreshErr == nil {
+					refreshErr = err
+				}
+			}
+		}
+	}
+
+	return refreshErr
+}
+
+// refreshAfterGrab refreshes the media servers for the directories that
+// episodes were grabbed into. The episodes are already grabbed, so a failure
+// is reported without failing the grab.
+func refreshAfterGrab(f Flags, transfers []*transfer) {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, t := range transfers {
+		dir := filepath.Dir(t.Dst)
+		if t.done && t.err == nil && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return
+	}
+
+	if err := RefreshMediaServers(f, dirs); err != nil {
+		printError(f, err)
+	}
+}
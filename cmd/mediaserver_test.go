@@ -0,0 +1,96 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("RefreshMediaServers", func() {
+	var server *ghttp.Server
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("When refreshing Plex without a section", func() {
+		It("Should refresh the sections containing the directory", func() {
+			sections := `{"MediaContainer":{"Directory":[
+				{"key":"1","Location":[{"path":"/media/movies"}]},
+				{"key":"2","Location":[{"path":"/media/tv"}]}
+			]}}`
+
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/library/sections"),
+					ghttp.VerifyHeaderKV("X-Plex-Token", "token"),
+					ghttp.RespondWith(http.StatusOK, sections),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/library/sections/2/refresh", "path=%2Fmedia%2Ftv%2FWestworld"),
+					ghttp.VerifyHeaderKV("X-Plex-Token", "token"),
+					ghttp.RespondWith(http.StatusOK, ""),
+				),
+			)
+
+			f := Flags{
+				Plex:              server.URL(),
+				PlexToken:         "token",
+				MediaPathMappings: []string{"/home/me/tv=/media/tv"},
+			}
+
+			Expect(RefreshMediaServers(f, []string{"/home/me/tv/Westworld"})).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Describe("When refreshing Jellyfin", func() {
+		It("Should report the directory as updated", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/Library/Media/Updated"),
+					ghttp.VerifyHeaderKV("X-Emby-Token", "key"),
+					ghttp.VerifyJSON(`{"Updates":[{"Path":"/tv/Westworld","UpdateType":"Created"}]}`),
+					ghttp.RespondWith(http.StatusNoContent, ""),
+				),
+			)
+
+			f := Flags{Jellyfin: server.URL(), JellyfinAPIKey: "key"}
+
+			Expect(RefreshMediaServers(f, []string{"/tv/Westworld"})).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("Should not send the refresh through --proxy", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/Library/Media/Updated"),
+					ghttp.RespondWith(http.StatusNoContent, ""),
+				),
+			)
+
+			f := Flags{Jellyfin: server.URL(), JellyfinAPIKey: "key", Proxy: "http://127.0.0.1:1"}
+
+			Expect(RefreshMediaServers(f, []string{"/tv/Westworld"})).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("Should return an error if the refresh is rejected", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusUnauthorized, ""))
+
+			f := Flags{Jellyfin: server.URL(), JellyfinAPIKey: "wrong"}
+
+			err := RefreshMediaServers(f, []string{"/tv/Westworld"})
+			Expect(err).To(HaveOccurred())
+			Expect(ErrorCode(err)).To(Equal("media_server_refresh_failed"))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// event is a single line of machine-readable output, written when sgrab is
+// run with --output json.
+type event struct {
+	Event         string     `json:"event"`
+	Time          time.Time  `json:"time"`
+	Series        string     `json:"series,omitempty"`
+	SeriesID      int        `json:"seriesId,omitempty"`
+	EpisodeID     int        `json:"episodeId,omitempty"`
+	EpisodeFileID int        `json:"episodeFileId,omitempty"`
+	QueueID       int        `json:"queueId,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	Remote        string     `json:"remotePath,omitempty"`
+	Path          string     `json:"path,omitempty"`
+	Size          int64      `json:"size,omitempty"`
+	Transferred   int64      `json:"transferred,omitempty"`
+	Attempt       int        `json:"attempt,omitempty"`
+	SHA256        string     `json:"sha256,omitempty"`
+	Added         *time.Time `json:"added,omitempty"`
+	Code          string     `json:"code,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+const (
+	EventSeries   = "series"
+	EventEpisode  = "episode"
+	EventPlan     = "plan"
+	EventStart    = "start"
+	EventProgress = "progress"
+	EventRetry    = "retry"
+	EventComplete = "complete"
+	EventError    = "error"
+	EventQueued   = "queued"
+	EventWatched  = "watched"
+)
+
+var (
+	// output is where events and plans are written
+	output  io.Writer = os.Stdout
+	eventMu sync.Mutex
+)
+
+// emit writes e to stdout as a JSON line if JSON output was requested, and
+// does nothing otherwise.
+func emit(f Flags, e event) {
+	if f.Output != OutputJSON {
+		return
+	}
+
+	e.Time = time.Now().UTC()
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	eventMu.Lock()
+	defer eventMu.Unlock()
+
+	fmt.Fprintln(output, string(buf))
+}
+
+// emitError reports err as an error event with its stable code.
+func emitError(f Flags, e event, err error) {
+	e.Event = EventError
+	e.Code = ErrorCode(err)
+	e.Error = err.Error()
+	emit(f, e)
+}
+
+// reportedError is an error that has already been emitted as an error event,
+// with more context than printError has, so it is not emitted again.
+type reportedError struct {
+	error
+}
+
+func (e reportedError) Unwrap() error {
+	return e.error
+}
+
+// printError reports err as an error event when JSON output was requested, or
+// prints it otherwise.
+func printError(f Flags, err error) {
+	if f.Output == OutputJSON {
+		if !errors.As(err, &reportedError{}) {
+			emitError(f, event{}, err)
+		}
+		return
+	}
+
+	fmt.Println(err)
+}
+
+func hashFile(fs afero.Fs, path string) (string, error) {
+	file, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
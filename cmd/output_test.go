@@ -0,0 +1,47 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrintError", func() {
+	var (
+		out     *bytes.Buffer
+		restore func()
+	)
+
+	f := Flags{Output: OutputJSON}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		restore = SetOutput(out)
+	})
+
+	AfterEach(func() {
+		restore()
+	})
+
+	It("Should emit an error event with the code of the error", func() {
+		PrintError(f, ErrCredentialsRejected)
+
+		var e map[string]interface{}
+		Expect(json.Unmarshal(out.Bytes(), &e)).To(Succeed())
+		Expect(e).To(HaveKeyWithValue("event", EventError))
+		Expect(e).To(HaveKeyWithValue("code", "credentials_rejected"))
+	})
+
+	It("Should not emit an error already reported by a transfer again", func() {
+		err := Reported(ErrCredentialsRejected)
+		PrintError(f, err)
+
+		Expect(out.String()).To(BeEmpty())
+		Expect(ErrorCode(err)).To(Equal("credentials_rejected"))
+		Expect(ExitCode(err)).To(Equal(ExitCredentials))
+	})
+})
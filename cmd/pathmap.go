@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"path"
+	"strings"
+)
+
+// pathMapping rewrites paths reported by Sonarr, which are often paths inside
+// a container, to the paths visible over SFTP.
+type pathMapping struct {
+	From string
+	To   string
+}
+
+// parsePathMappings parses mappings in the format "/sonarr/path=/sftp/path".
+func parsePathMappings(rules []string) ([]pathMapping, error) {
+	var mappings []pathMapping
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, ErrInvalidPathMapping(rule)
+		}
+
+		mappings = append(mappings, pathMapping{From: path.Clean(parts[0]), To: path.Clean(parts[1])})
+	}
+
+	return mappings, nil
+}
+
+// mapPath applies the mapping with the longest matching prefix to p. Prefixes
+// only match whole path elements, so "/tv" matches "/tv/Show" but not
+// "/tvshows". Paths that match no mapping are returned unchanged.
+func mapPath(mappings []pathMapping, p string) string {
+	var best pathMapping
+	for _, m := range mappings {
+		if (p == m.From || strings.HasPrefix(p, strings.TrimSuffix(m.From, "/")+"/")) && len(m.From) > len(best.From) {
+			best = m
+		}
+	}
+
+	if len(best.From) == 0 {
+		return p
+	}
+
+	return path.Join(best.To, strings.TrimPrefix(p, best.From))
+}
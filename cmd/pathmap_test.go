@@ -0,0 +1,40 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path mappings", func() {
+	table.DescribeTable("MapPath",
+		func(rules []string, p, mapped string) {
+			mappings, err := ParsePathMappings(rules)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(MapPath(mappings, p)).To(Equal(mapped))
+		},
+		table.Entry("no mappings", nil, "/tv/Show/S01E01.mkv", "/tv/Show/S01E01.mkv"),
+		table.Entry("a matching prefix", []string{"/tv=/home/me/tv"}, "/tv/Show/S01E01.mkv", "/home/me/tv/Show/S01E01.mkv"),
+		table.Entry("a trailing slash", []string{"/tv/=/home/me/tv/"}, "/tv/Show/S01E01.mkv", "/home/me/tv/Show/S01E01.mkv"),
+		table.Entry("the prefix itself", []string{"/tv=/home/me/tv"}, "/tv", "/home/me/tv"),
+		table.Entry("the longest prefix", []string{"/tv=/home/me/tv", "/tv/anime=/home/me/anime"}, "/tv/anime/Show/S01E01.mkv", "/home/me/anime/Show/S01E01.mkv"),
+		table.Entry("the longest prefix given first", []string{"/tv/anime=/home/me/anime", "/tv=/home/me/tv"}, "/tv/Show/S01E01.mkv", "/home/me/tv/Show/S01E01.mkv"),
+		table.Entry("a partial path element", []string{"/tv=/home/me/tv"}, "/tvshows/Show/S01E01.mkv", "/tvshows/Show/S01E01.mkv"),
+		table.Entry("the root", []string{"/=/home/me"}, "/tv/Show/S01E01.mkv", "/home/me/tv/Show/S01E01.mkv"),
+		table.Entry("a longer prefix than the root", []string{"/=/home/me", "/tv=/media/tv"}, "/tv/Show/S01E01.mkv", "/media/tv/Show/S01E01.mkv"),
+		table.Entry("to the root", []string{"/data=/"}, "/data/tv/Show/S01E01.mkv", "/tv/Show/S01E01.mkv"),
+	)
+
+	table.DescribeTable("ParsePathMappings should reject malformed rules",
+		func(rule string) {
+			_, err := ParsePathMappings([]string{"/tv=/home/me/tv", rule})
+			Expect(err).To(MatchError(ErrInvalidPathMapping(rule)))
+		},
+		table.Entry("no separator", "/tv"),
+		table.Entry("no source", "=/home/me/tv"),
+		table.Entry("no destination", "/tv="),
+		table.Entry("an empty rule", ""),
+	)
+})
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+func init() {
+	proxy.RegisterDialerType("http", newHTTPConnectDialer)
+}
+
+// getenv returns the value of the first of the given environment variables
+// that is set, checking the lowercase form of each as well.
+func getenv(names ...string) string {
+	for _, name := range names {
+		for _, n := range []string{name, strings.ToLower(name)} {
+			if value := os.Getenv(n); len(value) > 0 {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// httpProxy returns the proxy function for the Sonarr HTTP client. The --proxy
+// flag takes precedence over HTTPS_PROXY, HTTP_PROXY and NO_PROXY, which in
+// turn take precedence over ALL_PROXY.
+func httpProxy(f Flags) (func(*http.Request) (*url.URL, error), error) {
+	if len(f.Proxy) > 0 {
+		u, err := url.Parse(f.Proxy)
+		if err != nil {
+			return nil, ErrInvalidProxy(f.Proxy)
+		}
+		return http.ProxyURL(u), nil
+	}
+
+	allProxy := getenv("ALL_PROXY")
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := http.ProxyFromEnvironment(req)
+		if u != nil || err != nil || len(allProxy) == 0 {
+			return u, err
+		}
+
+		return url.Parse(allProxy)
+	}, nil
+}
+
+// sshDialer returns the dialer used to make the first connection towards the
+// seedbox. The --proxy flag takes precedence over ALL_PROXY and HTTPS_PROXY,
+// and hosts in NO_PROXY are connected to directly.
+func sshDialer(f Flags) (proxy.Dialer, error) {
+	proxyURL := f.Proxy
+	if len(proxyURL) == 0 {
+		proxyURL = getenv("ALL_PROXY", "HTTPS_PROXY")
+	}
+
+	if len(proxyURL) == 0 {
+		return proxy.Direct, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, ErrInvalidProxy(proxyURL)
+	}
+
+	dialer, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		return nil, ErrInvalidProxy(proxyURL)
+	}
+
+	if noProxy := getenv("NO_PROXY"); len(noProxy) > 0 && len(f.Proxy) == 0 {
+		perHost := proxy.NewPerHost(dialer, proxy.Direct)
+		perHost.AddFromString(noProxy)
+		return perHost, nil
+	}
+
+	return dialer, nil
+}
+
+// httpConnectDialer tunnels connections through an HTTP proxy using the
+// CONNECT method.
+type httpConnectDialer struct {
+	proxy   *url.URL
+	forward proxy.Dialer
+}
+
+func newHTTPConnectDialer(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &httpConnectDialer{proxy: u, forward: forward}, nil
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxy.Host
+	if len(d.proxy.Port()) == 0 {
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), "80")
+	}
+
+	conn, err := d.forward.Dial(network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		credentials := fmt.Sprintf("%s:%s", d.proxy.User.Username(), password)
+		req.Header.Set("Proxy-Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(credentials))))
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, ErrProxyConnectFailed(addr, res.Status)
+	}
+
+	// The SSH server speaks first, so its banner may already be buffered
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,210 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// listenBanner accepts connections and greets each one with banner, as an SSH
+// server would.
+func listenBanner(banner string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			fmt.Fprintf(conn, "%s\r\n", banner)
+		}
+	}()
+
+	return l
+}
+
+// listenProxy accepts CONNECT requests, passes each one to requests and
+// replies with status. A banner follows a 200 in the same write, so that it
+// is read along with the reply.
+func listenProxy(status string, requests chan<- *http.Request) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			requests <- req
+
+			reply := fmt.Sprintf("HTTP/1.1 %s\r\n\r\n", status)
+			if status == "200 Connection established" {
+				reply += "SSH-2.0-proxy\r\n"
+			}
+			fmt.Fprint(conn, reply)
+		}
+	}()
+
+	return l
+}
+
+// readBanner dials addr with f and returns the first line sent back.
+func readBanner(f Flags, addr string) string {
+	dialer, err := SSHDialer(f)
+	Expect(err).NotTo(HaveOccurred())
+
+	conn, err := dialer.Dial("tcp", addr)
+	Expect(err).NotTo(HaveOccurred())
+	defer conn.Close()
+
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	Expect(err).NotTo(HaveOccurred())
+
+	return banner
+}
+
+var proxyVars = []string{"ALL_PROXY", "HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"}
+
+var _ = Describe("Proxies", func() {
+	var (
+		seedbox  net.Listener
+		proxy    net.Listener
+		requests chan *http.Request
+		env      map[string]string
+	)
+
+	BeforeEach(func() {
+		seedbox = listenBanner("SSH-2.0-direct")
+		requests = make(chan *http.Request, 1)
+
+		// Start each spec with no proxy configured in the environment
+		env = map[string]string{}
+		for _, name := range proxyVars {
+			for _, n := range []string{name, strings.ToLower(name)} {
+				if value, ok := os.LookupEnv(n); ok {
+					env[n] = value
+				}
+				os.Unsetenv(n)
+			}
+		}
+	})
+
+	AfterEach(func() {
+		seedbox.Close()
+		if proxy != nil {
+			proxy.Close()
+		}
+
+		for _, name := range proxyVars {
+			for _, n := range []string{name, strings.ToLower(name)} {
+				os.Unsetenv(n)
+				if value, ok := env[n]; ok {
+					os.Setenv(n, value)
+				}
+			}
+		}
+	})
+
+	Describe("When tunnelling through an HTTP proxy", func() {
+		It("Should send the credentials and read a banner buffered behind the reply", func() {
+			proxy = listenProxy("200 Connection established", requests)
+
+			f := Flags{Proxy: fmt.Sprintf("http://user:pass@%s", proxy.Addr())}
+			Expect(readBanner(f, "seedbox.example.com:22")).To(Equal("SSH-2.0-proxy\r\n"))
+
+			req := <-requests
+			Expect(req.Method).To(Equal(http.MethodConnect))
+			Expect(req.Host).To(Equal("seedbox.example.com:22"))
+			Expect(req.Header.Get("Proxy-Authorization")).To(Equal("Basic dXNlcjpwYXNz"))
+		})
+
+		It("Should return an error if the proxy refuses to connect", func() {
+			proxy = listenProxy("407 Proxy Authentication Required", requests)
+
+			dialer, err := SSHDialer(Flags{Proxy: fmt.Sprintf("http://%s", proxy.Addr())})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = dialer.Dial("tcp", "seedbox.example.com:22")
+			Expect(err).To(MatchError(ErrProxyConnectFailed("seedbox.example.com:22", "407 Proxy Authentication Required")))
+			Expect((<-requests).Header.Get("Proxy-Authorization")).To(BeEmpty())
+		})
+	})
+
+	Describe("When choosing a proxy for the seedbox", func() {
+		BeforeEach(func() {
+			proxy = listenProxy("200 Connection established", requests)
+		})
+
+		It("Should connect directly if no proxy is configured", func() {
+			Expect(readBanner(Flags{}, seedbox.Addr().String())).To(Equal("SSH-2.0-direct\r\n"))
+		})
+
+		It("Should use HTTPS_PROXY", func() {
+			os.Setenv("HTTPS_PROXY", fmt.Sprintf("http://%s", proxy.Addr()))
+			Expect(readBanner(Flags{}, seedbox.Addr().String())).To(Equal("SSH-2.0-proxy\r\n"))
+		})
+
+		It("Should connect directly to hosts in NO_PROXY", func() {
+			os.Setenv("ALL_PROXY", fmt.Sprintf("http://%s", proxy.Addr()))
+			os.Setenv("NO_PROXY", "localhost,127.0.0.1")
+			Expect(readBanner(Flags{}, seedbox.Addr().String())).To(Equal("SSH-2.0-direct\r\n"))
+		})
+
+		It("Should prefer --proxy to the environment", func() {
+			refused := listenProxy("403 Forbidden", make(chan *http.Request, 1))
+			defer refused.Close()
+
+			os.Setenv("ALL_PROXY", fmt.Sprintf("http://%s", refused.Addr()))
+			os.Setenv("HTTPS_PROXY", fmt.Sprintf("http://%s", refused.Addr()))
+			os.Setenv("NO_PROXY", "127.0.0.1")
+
+			f := Flags{Proxy: fmt.Sprintf("http://%s", proxy.Addr())}
+			Expect(readBanner(f, seedbox.Addr().String())).To(Equal("SSH-2.0-proxy\r\n"))
+		})
+
+		It("Should return an error for an unsupported proxy", func() {
+			_, err := SSHDialer(Flags{Proxy: "ftp://proxy.example.com"})
+			Expect(err).To(MatchError(ErrInvalidProxy("ftp://proxy.example.com")))
+		})
+	})
+
+	Describe("When choosing a proxy for Sonarr", func() {
+		It("Should prefer --proxy to the environment", func() {
+			os.Setenv("HTTPS_PROXY", "http://env.example.com:3128")
+			os.Setenv("ALL_PROXY", "http://env.example.com:3128")
+
+			proxyFunc, err := HTTPProxy(Flags{Proxy: "http://flag.example.com:3128"})
+			Expect(err).NotTo(HaveOccurred())
+
+			req, err := http.NewRequest(http.MethodGet, "https://sonarr.example.com/api/series", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			u, err := proxyFunc(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.String()).To(Equal("http://flag.example.com:3128"))
+		})
+
+		It("Should return an error for a proxy that cannot be parsed", func() {
+			_, err := HTTPProxy(Flags{Proxy: "http://[::1"})
+			Expect(err).To(MatchError(ErrInvalidProxy("http://[::1")))
+		})
+	})
+})
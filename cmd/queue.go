@@ -0,0 +1,374 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const (
+	QueueItemPending = "pending"
+	QueueItemDone    = "done"
+	QueueItemFailed  = "failed"
+)
+
+// queueItem is an episode file waiting to be grabbed by 'sgrab queue run'.
+type queueItem struct {
+	ID            int       `json:"id"`
+	Series        string    `json:"series"`
+	SeriesID      int       `json:"seriesId,omitempty"`
+	EpisodeFileID int       `json:"episodeFileId"`
+	EpisodeIDs    []int     `json:"episodeIds,omitempty"`
+	Dst           string    `json:"destination"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	Added         time.Time `json:"added"`
+}
+
+// queue is the list of queued episode files, persisted as JSON between runs.
+type queue struct {
+	NextID int         `json:"nextId"`
+	Items  []queueItem `json:"items"`
+
+	mu sync.Mutex
+}
+
+func loadQueue(fs afero.Fs, path string) (*queue, error) {
+	q := &queue{NextID: 1}
+
+	buf, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, q); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Save writes the queue to a temporary file next to path and renames it over
+// path, so that an interrupt while saving never leaves a truncated queue.
+func (q *queue) Save(fs afero.Fs, path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	buf, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := afero.TempFile(fs, filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		fs.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// Add queues t and returns the new item.
+func (q *queue) Add(t *transfer) queueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item := queueItem{
+		ID:            q.NextID,
+		Series:        t.Series,
+		SeriesID:      t.SeriesID,
+		EpisodeFileID: t.EpisodeFileID,
+		EpisodeIDs:    t.EpisodeIDs,
+		Dst:           t.Dst,
+		Status:        QueueItemPending,
+		Added:         time.Now(),
+	}
+	q.Items = append(q.Items, item)
+	q.NextID++
+
+	return item
+}
+
+func (q *queue) Remove(id int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.Items {
+		if item.ID == id {
+			q.Items = append(q.Items[:i], q.Items[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrQueueItemNotFound(strconv.Itoa(id))
+}
+
+// Finish records the result of grabbing the item with the given ID.
+func (q *queue) Finish(id int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.Items {
+		if q.Items[i].ID != id {
+			continue
+		}
+
+		q.Items[i].Status, q.Items[i].Error = QueueItemDone, ""
+		if err != nil {
+			q.Items[i].Status, q.Items[i].Error = QueueItemFailed, err.Error()
+		}
+	}
+}
+
+// RunQueue grabs every queued item that has not been grabbed yet, resuming
+// partially grabbed files, and marks each one as done or failed as soon as it
+// finishes.
+func RunQueue(fs afero.Fs, f Flags, c sonarr.SonarrClient, path string) error {
+	q, err := loadQueue(fs, path)
+	if err != nil {
+		return err
+	}
+
+	var transfers []*transfer
+	ids := map[*transfer]int{}
+	for _, item := range q.Items {
+		if item.Status == QueueItemDone {
+			continue
+		}
+
+		// The path on the seedbox may have changed since the item was queued
+		t, err := resolveTransfer(f, c, item.EpisodeFileID, filepath.Dir(item.Dst))
+		if err != nil {
+			q.Finish(item.ID, err)
+			continue
+		}
+
+		t.Series = item.Series
+		t.SeriesID = item.SeriesID
+		t.EpisodeIDs = item.EpisodeIDs
+		t.Dst = item.Dst
+		t.Resume = true
+		transfers = append(transfers, t)
+		ids[t] = item.ID
+	}
+
+	// Leave the queue as it is, since nothing is grabbed
+	if f.DryRun {
+		return printPlan(fs, f, transfers, output)
+	}
+
+	if len(transfers) == 0 {
+		return q.Save(fs, path)
+	}
+
+	err = grab(fs, f, transfers, func(t *transfer) {
+		q.Finish(ids[t], t.err)
+		q.Save(fs, path)
+	})
+	if saveErr := q.Save(fs, path); saveErr != nil && err == nil {
+		err = saveErr
+	}
+
+	return err
+}
+
+var queueFile string
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Queue episodes to grab later.",
+	Long: `Queue episodes to grab later, e.g. queue them at work and grab them at home.
+
+The queue is kept in $HOME/.sgrab/queue.json, or the file given with the
+--queue-file flag. Items that fail or are interrupted stay in the queue and are
+resumed from where they left off the next time the queue is run.
+
+Example:
+
+sgrab queue add --series Westworld --episode s01e01-s01e10
+sgrab queue list
+sgrab queue run
+`,
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add episodes to the queue.",
+	Long: `Add episodes to the queue, using the --series and --episode flags in the
+same way as grabbing them directly. The destination is the present working
+directory.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs, f, sonarrClient, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !hasSonarrFlags(f) || !hasSeriesSelector(f) || !hasEpisodeSelector(f) {
+			return ErrInformationMissing
+		}
+
+		q, err := loadQueue(fs, queueFile)
+		if err != nil {
+			return err
+		}
+
+		transfers, err := resolveTransfers(f, sonarrClient)
+		if err != nil {
+			return err
+		}
+
+		if f.DryRun {
+			return printPlan(fs, f, transfers, output)
+		}
+
+		var added []queueItem
+		for _, t := range transfers {
+			added = append(added, q.Add(t))
+		}
+
+		if err := q.Save(fs, queueFile); err != nil {
+			return err
+		}
+
+		if f.Output == OutputJSON {
+			for _, item := range added {
+				emitQueueItem(f, item)
+			}
+			return nil
+		}
+
+		fmt.Printf("Queued %d episode(s).\n", len(transfers))
+		return nil
+	},
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the queue.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs, f, _, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		q, err := loadQueue(fs, queueFile)
+		if err != nil {
+			return err
+		}
+
+		if f.Output == OutputJSON {
+			for _, item := range q.Items {
+				emitQueueItem(f, item)
+			}
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSERIES\tDESTINATION\tSTATUS\tADDED")
+		for _, item := range q.Items {
+			status := item.Status
+			if len(item.Error) > 0 {
+				status = fmt.Sprintf("%s: %s", status, item.Error)
+			}
+
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", item.ID, item.Series, item.Dst, status, item.Added.Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	},
+}
+
+// emitQueueItem reports item as a queued event.
+func emitQueueItem(f Flags, item queueItem) {
+	added := item.Added
+	emit(f, event{
+		Event:         EventQueued,
+		QueueID:       item.ID,
+		Series:        item.Series,
+		SeriesID:      item.SeriesID,
+		EpisodeFileID: item.EpisodeFileID,
+		Path:          item.Dst,
+		Status:        item.Status,
+		Added:         &added,
+		Error:         item.Error,
+	})
+}
+
+var queueRemoveCmd = &cobra.Command{
+	Use:   "remove ID...",
+	Short: "Remove items from the queue.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs := afero.NewOsFs()
+
+		q, err := loadQueue(fs, queueFile)
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return ErrQueueItemNotFound(arg)
+			}
+
+			if err := q.Remove(id); err != nil {
+				return err
+			}
+		}
+
+		return q.Save(fs, queueFile)
+	},
+}
+
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Grab every episode in the queue that has not been grabbed yet.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs, f, sonarrClient, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !hasSonarrFlags(f) || (!hasSeedboxFlags(f) && !f.DryRun) {
+			return ErrInformationMissing
+		}
+
+		return RunQueue(fs, f, sonarrClient, queueFile)
+	},
+}
+
+func init() {
+	queueCmd.PersistentFlags().StringVar(&queueFile, "queue-file", fmt.Sprintf("%s/.sgrab/queue.json", os.Getenv("HOME")), "Path to queue file")
+
+	queueCmd.AddCommand(queueAddCmd, queueListCmd, queueRemoveCmd, queueRunCmd)
+	RootCmd.AddCommand(queueCmd)
+}
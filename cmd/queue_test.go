@@ -0,0 +1,168 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/lgug2z/sgrab/sonarr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Queue", func() {
+	var fs afero.Fs
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+	})
+
+	It("Should give every added item a new ID", func() {
+		q, err := LoadQueue(fs, "/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		first := q.Add(NewTransfer("Westworld", 1, "/tv/S01E01.mkv"))
+		second := q.Add(NewTransfer("Westworld", 2, "/tv/S01E02.mkv"))
+
+		Expect(first.ID).To(Equal(1))
+		Expect(first.Status).To(Equal(QueueItemPending))
+		Expect(second.ID).To(Equal(2))
+		Expect(q.NextID).To(Equal(3))
+	})
+
+	It("Should not reuse the ID of a removed item", func() {
+		q, err := LoadQueue(fs, "/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		q.Add(NewTransfer("Westworld", 1, "/tv/S01E01.mkv"))
+		Expect(q.Remove(1)).To(Succeed())
+		Expect(q.Items).To(BeEmpty())
+
+		Expect(q.Add(NewTransfer("Westworld", 2, "/tv/S01E02.mkv")).ID).To(Equal(2))
+	})
+
+	It("Should return an error when removing an item that is not queued", func() {
+		q, err := LoadQueue(fs, "/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(q.Remove(1)).To(MatchError(ErrQueueItemNotFound("1")))
+	})
+
+	It("Should mark finished items as done or failed", func() {
+		q, err := LoadQueue(fs, "/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		q.Add(NewTransfer("Westworld", 1, "/tv/S01E01.mkv"))
+		q.Add(NewTransfer("Westworld", 2, "/tv/S01E02.mkv"))
+
+		q.Finish(2, errors.New("connection lost"))
+		Expect(q.Items[1].Status).To(Equal(QueueItemFailed))
+		Expect(q.Items[1].Error).To(Equal("connection lost"))
+
+		q.Finish(2, nil)
+		Expect(q.Items[0].Status).To(Equal(QueueItemPending))
+		Expect(q.Items[1].Status).To(Equal(QueueItemDone))
+		Expect(q.Items[1].Error).To(BeEmpty())
+	})
+
+	It("Should save the queue without leaving a temporary file behind", func() {
+		q, err := LoadQueue(fs, "/sgrab/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		q.Add(NewTransfer("Westworld", 1, "/tv/S01E01.mkv"))
+		Expect(q.Save(fs, "/sgrab/queue.json")).To(Succeed())
+		Expect(q.Save(fs, "/sgrab/queue.json")).To(Succeed())
+
+		names, err := afero.ReadDir(fs, "/sgrab")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(HaveLen(1))
+		Expect(names[0].Name()).To(Equal("queue.json"))
+
+		saved, err := LoadQueue(fs, "/sgrab/queue.json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved.NextID).To(Equal(2))
+		Expect(saved.Items).To(HaveLen(1))
+		Expect(saved.Items[0].Dst).To(Equal("/tv/S01E01.mkv"))
+	})
+
+	Describe("When run", func() {
+		var (
+			server  *ghttp.Server
+			client  sonarr.Client
+			seedbox *testSeedbox
+			remote  string
+			restore func()
+		)
+
+		BeforeEach(func() {
+			var err error
+			remote, err = ioutil.TempDir("", "sgrab-queue")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ioutil.WriteFile(filepath.Join(remote, "S01E01.mkv"), []byte("the original"), 0644)).To(Succeed())
+
+			server = ghttp.NewServer()
+			client = sonarr.Client{URL: server.URL(), APIKey: "key", Client: http.Client{}}
+			seedbox = startTestSeedbox(0)
+			restore = SetOutput(&bytes.Buffer{})
+
+			// s01e02 is known to Sonarr but missing from the seedbox
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episodeFile/1"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, sonarr.EpisodeFile{ID: 1, Path: filepath.Join(remote, "S01E01.mkv"), Size: 12}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/api/episodeFile/2"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, sonarr.EpisodeFile{ID: 2, Path: filepath.Join(remote, "S01E02.mkv"), Size: 12}),
+				),
+			)
+
+			q, err := LoadQueue(fs, "/queue.json")
+			Expect(err).NotTo(HaveOccurred())
+
+			q.Add(NewTransfer("Westworld", 1, "/tv/S01E01.mkv"))
+			q.Add(NewTransfer("Westworld", 2, "/tv/S01E02.mkv"))
+			q.Add(NewTransfer("Westworld", 3, "/tv/S01E03.mkv"))
+			q.Finish(3, nil)
+			Expect(q.Save(fs, "/queue.json")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			restore()
+			seedbox.Close()
+			server.Close()
+			os.RemoveAll(remote)
+		})
+
+		It("Should mark each item as done or failed", func() {
+			f := seedbox.Flags(filepath.Join(remote, "known_hosts"))
+			f.Output = OutputJSON
+
+			Expect(RunQueue(fs, f, client, "/queue.json")).NotTo(Succeed())
+
+			q, err := LoadQueue(fs, "/queue.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(q.Items).To(HaveLen(3))
+
+			Expect(q.Items[0].Status).To(Equal(QueueItemDone))
+			Expect(q.Items[1].Status).To(Equal(QueueItemFailed))
+			Expect(q.Items[1].Error).NotTo(BeEmpty())
+			Expect(q.Items[2].Status).To(Equal(QueueItemDone))
+
+			grabbed, err := afero.ReadFile(fs, "/tv/S01E01.mkv")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(grabbed)).To(Equal("the original"))
+
+			// Only the items that were not done are looked up
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSeedbox is an SSH server with an SFTP subsystem serving the local
+// filesystem, standing in for a seedbox in specs. It accepts the user "u"
+// with the password "secret".
+type testSeedbox struct {
+	listener  net.Listener
+	key       ssh.PublicKey
+	dropAfter int64
+
+	mu          sync.Mutex
+	connections int
+}
+
+// startTestSeedbox starts a seedbox listening on a random local port. If
+// dropAfter is not 0, the first connection is dropped once that many bytes
+// have been sent over it.
+func startTestSeedbox(dropAfter int64) *testSeedbox {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	signer, err := ssh.NewSignerFromKey(private)
+	Expect(err).NotTo(HaveOccurred())
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "u" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	s := &testSeedbox{listener: listener, key: signer.PublicKey(), dropAfter: dropAfter}
+	go s.serve(config)
+
+	return s
+}
+
+// Flags returns the flags needed to grab from the seedbox, trusting its host
+// key and recording it in knownHosts.
+func (s *testSeedbox) Flags(knownHosts string) Flags {
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	Expect(err).NotTo(HaveOccurred())
+
+	return Flags{
+		SeedboxURL:         host,
+		Port:               port,
+		Username:           "u",
+		Password:           "secret",
+		AuthMethods:        []string{AuthPassword},
+		KnownHosts:         knownHosts,
+		HostKeyFingerprint: ssh.FingerprintSHA256(s.key),
+		Jobs:               1,
+	}
+}
+
+// Connections returns the number of connections made to the seedbox.
+func (s *testSeedbox) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connections
+}
+
+func (s *testSeedbox) Close() {
+	s.listener.Close()
+}
+
+func (s *testSeedbox) serve(config *ssh.ServerConfig) {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.connections++
+		first := s.connections == 1
+		s.mu.Unlock()
+
+		var conn net.Conn = c
+		if first && s.dropAfter > 0 {
+			conn = &droppingConn{Conn: c, max: s.dropAfter}
+		}
+
+		go serveSFTP(conn, config)
+	}
+}
+
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	sc, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sc.Close()
+
+	go ssh.DiscardRequests(requests)
+	for nc := range channels {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, nc.ChannelType())
+			continue
+		}
+
+		channel, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func(requests <-chan *ssh.Request) {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem", nil)
+			}
+		}(requests)
+
+		go func(channel ssh.Channel) {
+			defer channel.Close()
+
+			server, err := sftp.NewServer(channel)
+			if err == nil {
+				server.Serve()
+			}
+		}(channel)
+	}
+}
+
+// droppingConn closes the connection once max bytes have been written to it.
+type droppingConn struct {
+	net.Conn
+	written int64
+	max     int64
+}
+
+func (c *droppingConn) Write(b []byte) (int, error) {
+	if c.written >= c.max {
+		c.Conn.Close()
+		return 0, io.ErrClosedPipe
+	}
+
+	n, err := c.Conn.Write(b)
+	c.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// session owns the SSH connection and SFTP client to the seedbox for a whole
+// run, so that every file transferred shares a single connection. It is safe
+// for concurrent use. If the connection drops, the next call to SFTP
+// transparently reconnects.
+type session struct {
+	f    Flags
+	auth []ssh.AuthMethod
+
+	mu     sync.Mutex
+	conn   *seedboxConn
+	client *sftp.Client
+	closed bool
+}
+
+func newSession(f Flags, auth []ssh.AuthMethod) *session {
+	return &session{f: f, auth: auth}
+}
+
+// SFTP returns the SFTP client for the current connection, connecting to the
+// seedbox first if there is no live connection.
+func (s *session) SFTP() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	conn, err := dialSeedbox(s.f, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn.Client)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s.conn, s.client = conn, client
+	logger.Debug("sftp session opened", "host", s.f.SeedboxURL)
+
+	go s.keepAlive(conn, client)
+	go func() {
+		conn.Wait()
+		s.drop(client)
+	}()
+
+	return client, nil
+}
+
+// OpenAt opens the file at path on the seedbox, reconnecting first if needed,
+// and seeks to offset. The SFTP client the file was opened with is returned
+// so that it can be passed to Reset if reading from the file fails.
+func (s *session) OpenAt(path string, offset int64) (*sftp.Client, *sftp.File, error) {
+	client, err := s.SFTP()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := client.Open(path)
+	if err != nil {
+		return client, nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return client, nil, err
+	}
+
+	return client, file, nil
+}
+
+// Reset drops the connection that client belongs to, if it is still current,
+// so that the next call to SFTP reconnects. Callers use it after a transfer
+// fails part way through.
+func (s *session) Reset(client *sftp.Client) {
+	s.drop(client)
+}
+
+func (s *session) drop(client *sftp.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != client || s.client == nil {
+		return
+	}
+
+	logger.Info("connection dropped", "host", s.f.SeedboxURL)
+	s.client.Close()
+	s.conn.Close()
+	s.conn, s.client = nil, nil
+}
+
+// keepAlive sends OpenSSH keepalive requests until the connection is dropped,
+// dropping it itself if the seedbox stops responding.
+func (s *session) keepAlive(conn *seedboxConn, client *sftp.Client) {
+	if s.f.KeepAlive <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.f.KeepAlive)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		current := s.client == client
+		s.mu.Unlock()
+
+		if !current {
+			return
+		}
+
+		if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			logger.Info("keepalive failed", "host", s.f.SeedboxURL, "error", err)
+			s.drop(client)
+			return
+		}
+	}
+}
+
+// Close closes the connection to the seedbox. The session cannot be used
+// afterwards.
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.client == nil {
+		return nil
+	}
+
+	s.client.Close()
+	err := s.conn.Close()
+	s.conn, s.client = nil, nil
+
+	return err
+}
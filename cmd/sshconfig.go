@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/afero"
+)
+
+// loadSSHConfig parses the OpenSSH client config at path. A missing file is
+// not an error and results in an empty config. Neither is a file the parser
+// does not understand, e.g. one using "Match exec", since most commands never
+// connect to the seedbox; it is logged and ignored.
+func loadSSHConfig(fs afero.Fs, path string) (*ssh_config.Config, error) {
+	file, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return &ssh_config.Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg, err := ssh_config.Decode(file)
+	if err != nil {
+		logger.Info("ignoring ssh config", "path", path, "error", err)
+		return &ssh_config.Config{}, nil
+	}
+
+	return cfg, nil
+}
+
+// applySSHConfig treats the seedbox address as a Host alias in the OpenSSH
+// client config and fills in every connection setting that was not given
+// explicitly. explicit reports whether the flag with the given name was set
+// on the command line or in the environment.
+func applySSHConfig(f Flags, cfg *ssh_config.Config, explicit func(flag string) bool) (Flags, error) {
+	alias := f.SeedboxURL
+
+	settings := []struct {
+		flag   string
+		key    string
+		target *string
+	}{
+		{"username", "User", &f.Username},
+		{"port", "Port", &f.Port},
+		{"ssh-key", "IdentityFile", &f.SSHKeyLocation},
+		{"jump", "ProxyJump", &f.Jump},
+	}
+
+	for _, s := range settings {
+		if explicit(s.flag) {
+			continue
+		}
+
+		value, err := cfg.Get(alias, s.key)
+		if err != nil {
+			return f, err
+		}
+
+		if len(value) > 0 {
+			*s.target = expandSSHConfigValue(value, alias)
+		}
+	}
+
+	jump, err := resolveJumpHosts(f.Jump, cfg)
+	if err != nil {
+		return f, err
+	}
+	f.Jump = jump
+
+	// The alias is resolved last so that the settings above are looked up by it
+	hostname, err := cfg.Get(alias, "HostName")
+	if err != nil {
+		return f, err
+	}
+
+	if len(hostname) > 0 {
+		f.SeedboxURL = expandSSHConfigValue(hostname, alias)
+	}
+
+	return f, nil
+}
+
+// resolveJumpHosts treats each hop in a jump specification as a Host alias
+// and fills in its HostName, User and Port from the config.
+func resolveJumpHosts(spec string, cfg *ssh_config.Config) (string, error) {
+	jumps, err := parseJumpHosts(spec)
+	if err != nil {
+		return "", err
+	}
+
+	var resolved []string
+	for _, j := range jumps {
+		alias := j.Host
+
+		if hostname, err := cfg.Get(alias, "HostName"); err != nil {
+			return "", err
+		} else if len(hostname) > 0 {
+			j.Host = expandSSHConfigValue(hostname, alias)
+		}
+
+		if user, err := cfg.Get(alias, "User"); err != nil {
+			return "", err
+		} else if len(user) > 0 && len(j.User) == 0 {
+			j.User = user
+		}
+
+		if port, err := cfg.Get(alias, "Port"); err != nil {
+			return "", err
+		} else if len(port) > 0 && len(j.Port) == 0 {
+			j.Port = port
+		}
+
+		resolved = append(resolved, j.String())
+	}
+
+	return strings.Join(resolved, ","), nil
+}
+
+// expandSSHConfigValue expands the tokens OpenSSH supports in HostName and
+// IdentityFile that are meaningful to sgrab.
+func expandSSHConfigValue(value, alias string) string {
+	if strings.HasPrefix(value, "~/") {
+		value = filepath.Join(os.Getenv("HOME"), strings.TrimPrefix(value, "~/"))
+	}
+
+	value = strings.Replace(value, "%h", alias, -1)
+	value = strings.Replace(value, "%d", os.Getenv("HOME"), -1)
+
+	return value
+}
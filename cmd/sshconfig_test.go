@@ -0,0 +1,106 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("SSH config", func() {
+	const config = `
+Host seedbox
+    HostName %h.example.com
+    User me
+    Port 2222
+    IdentityFile ~/.ssh/seedbox
+    ProxyJump bastion
+
+Host bastion
+    HostName bastion.example.com
+    User jumper
+    Port 2200
+`
+
+	decode := func(config string) *ssh_config.Config {
+		cfg, err := ssh_config.Decode(strings.NewReader(config))
+		Expect(err).NotTo(HaveOccurred())
+		return cfg
+	}
+
+	none := func(string) bool { return false }
+
+	Describe("LoadSSHConfig", func() {
+		It("Should return an empty config if the file does not exist", func() {
+			cfg, err := LoadSSHConfig(afero.NewMemMapFs(), "/missing")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Get("seedbox", "HostName")).To(BeEmpty())
+		})
+
+		It("Should ignore a config it cannot parse", func() {
+			fs := afero.NewMemMapFs()
+			Expect(afero.WriteFile(fs, "/config", []byte("Match exec \"true\"\n    User me\n"), 0600)).To(Succeed())
+
+			cfg, err := LoadSSHConfig(fs, "/config")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Get("seedbox", "User")).To(BeEmpty())
+		})
+	})
+
+	Describe("ApplySSHConfig", func() {
+		It("Should fill in the settings of the alias and resolve its HostName", func() {
+			f, err := ApplySSHConfig(Flags{SeedboxURL: "seedbox", Port: "22"}, decode(config), none)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.SeedboxURL).To(Equal("seedbox.example.com"))
+			Expect(f.Username).To(Equal("me"))
+			Expect(f.Port).To(Equal("2222"))
+			Expect(f.SSHKeyLocation).To(Equal(filepath.Join(os.Getenv("HOME"), ".ssh/seedbox")))
+			Expect(f.Jump).To(Equal("jumper@bastion.example.com:2200"))
+		})
+
+		It("Should prefer flags and environment variables", func() {
+			explicit := func(flag string) bool { return flag == "username" || flag == "port" }
+
+			f, err := ApplySSHConfig(Flags{SeedboxURL: "seedbox", Username: "other", Port: "22"}, decode(config), explicit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.SeedboxURL).To(Equal("seedbox.example.com"))
+			Expect(f.Username).To(Equal("other"))
+			Expect(f.Port).To(Equal("22"))
+		})
+
+		It("Should leave an address that is not an alias unchanged", func() {
+			f, err := ApplySSHConfig(Flags{SeedboxURL: "mybox.com", Username: "me", Port: "22"}, decode(config), none)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.SeedboxURL).To(Equal("mybox.com"))
+			Expect(f.Username).To(Equal("me"))
+			Expect(f.Port).To(Equal("22"))
+			Expect(f.Jump).To(BeEmpty())
+		})
+	})
+
+	Describe("ResolveJumpHosts", func() {
+		It("Should resolve each hop that is an alias", func() {
+			Expect(ResolveJumpHosts("bastion,other.example.com:2022", decode(config))).
+				To(Equal("jumper@bastion.example.com:2200,other.example.com:2022"))
+		})
+
+		It("Should prefer the user and port given in the hop", func() {
+			Expect(ResolveJumpHosts("me@bastion:22", decode(config))).To(Equal("me@bastion.example.com:22"))
+		})
+
+		It("Should return nothing for none", func() {
+			Expect(ResolveJumpHosts("none", decode(config))).To(BeEmpty())
+		})
+
+		It("Should return an error for an invalid hop", func() {
+			_, err := ResolveJumpHosts("me@", decode(config))
+			Expect(err).To(MatchError(ErrInvalidJumpHost("me@")))
+		})
+	})
+})
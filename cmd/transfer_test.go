@@ -0,0 +1,131 @@
+package cmd_test
+
+import (
+	. "github.com/lgug2z/sgrab/cmd"
+
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("CopyFile", func() {
+	var (
+		seedbox  *testSeedbox
+		fs       afero.Fs
+		dir      string
+		remote   string
+		contents []byte
+		f        Flags
+		restore  func()
+
+		// dropAfter is the number of bytes after which the first connection
+		// to the seedbox is dropped, if it is not 0
+		dropAfter int64
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sgrab-transfer")
+		Expect(err).NotTo(HaveOccurred())
+
+		contents = make([]byte, 1<<20)
+		rand.New(rand.NewSource(1)).Read(contents)
+
+		remote = filepath.Join(dir, "S01E01.mkv")
+		Expect(ioutil.WriteFile(remote, contents, 0644)).To(Succeed())
+
+		fs = afero.NewMemMapFs()
+		restore = SetOutput(&bytes.Buffer{})
+		dropAfter = 0
+	})
+
+	JustBeforeEach(func() {
+		seedbox = startTestSeedbox(dropAfter)
+		f = seedbox.Flags(filepath.Join(dir, "known_hosts"))
+	})
+
+	AfterEach(func() {
+		restore()
+		seedbox.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Should replace a file already at the destination", func() {
+		Expect(afero.WriteFile(fs, "/tv/S01E01.mkv", make([]byte, 2<<20), 0644)).To(Succeed())
+
+		Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", false)).To(Succeed())
+		Expect(afero.ReadFile(fs, "/tv/S01E01.mkv")).To(Equal(contents))
+	})
+
+	It("Should carry on from the end of a partial file when resuming", func() {
+		// The partial file differs from the remote one, so that appending
+		// to it can be told apart from grabbing the whole file again
+		partial := bytes.Repeat([]byte{'x'}, 1000)
+		Expect(afero.WriteFile(fs, "/tv/S01E01.mkv", partial, 0644)).To(Succeed())
+
+		Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", true)).To(Succeed())
+		Expect(afero.ReadFile(fs, "/tv/S01E01.mkv")).To(Equal(append(partial, contents[1000:]...)))
+	})
+
+	It("Should start again when resuming a file larger than the remote one", func() {
+		Expect(afero.WriteFile(fs, "/tv/S01E01.mkv", make([]byte, 2<<20), 0644)).To(Succeed())
+
+		Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", true)).To(Succeed())
+		Expect(afero.ReadFile(fs, "/tv/S01E01.mkv")).To(Equal(contents))
+	})
+
+	It("Should return an error if the remote file does not exist", func() {
+		missing := filepath.Join(dir, "S01E02.mkv")
+		Expect(CopyFile(fs, f, missing, "/tv/S01E02.mkv", false)).To(MatchError(ErrEpisodeFileNotFound(missing)))
+	})
+
+	Describe("When the connection drops part way through", func() {
+		BeforeEach(func() {
+			dropAfter = 256 << 10
+		})
+
+		It("Should reconnect and resume from where it left off", func() {
+			f.Retries = 1
+
+			Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", false)).To(Succeed())
+			Expect(afero.ReadFile(fs, "/tv/S01E01.mkv")).To(Equal(contents))
+			Expect(seedbox.Connections()).To(Equal(2))
+		})
+
+		It("Should delete the partial file after the last retry", func() {
+			Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", false)).NotTo(Succeed())
+			Expect(afero.Exists(fs, "/tv/S01E01.mkv")).To(BeFalse())
+		})
+
+		It("Should keep the partial file after the last retry when resuming", func() {
+			Expect(CopyFile(fs, f, remote, "/tv/S01E01.mkv", true)).NotTo(Succeed())
+
+			partial, err := afero.ReadFile(fs, "/tv/S01E01.mkv")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partial).NotTo(BeEmpty())
+			Expect(len(partial)).To(BeNumerically("<", len(contents)))
+			Expect(partial).To(Equal(contents[:len(partial)]))
+		})
+	})
+})
+
+var _ = Describe("Backoff", func() {
+	table.DescribeTable("Should double the wait after each attempt up to 30 seconds",
+		func(attempt int, wait time.Duration) {
+			Expect(Backoff(attempt)).To(Equal(wait))
+		},
+		table.Entry("the first retry", 1, time.Second),
+		table.Entry("the second retry", 2, 2*time.Second),
+		table.Entry("the fifth retry", 5, 16*time.Second),
+		table.Entry("the sixth retry", 6, 30*time.Second),
+		table.Entry("a retry far enough to overflow", 100, 30*time.Second),
+	)
+})
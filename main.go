@@ -1,7 +1,11 @@
 package main
 
-import "github.com/lgug2z/sgrab/cmd"
+import (
+	"os"
+
+	"github.com/lgug2z/sgrab/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	os.Exit(cmd.Execute())
 }
@@ -3,8 +3,10 @@ package sonarr
 import (
 	"encoding/json"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"errors"
 
@@ -17,9 +19,11 @@ const EpisodeFileEndpoint = "episodeFile/"
 const EpisodeEndpoint = "episode/"
 
 var ErrUnauthorized = errors.New("API key was rejected by Sonarr.")
+var ErrNotFound = errors.New("Not found on Sonarr.")
 
 type SonarrClient interface {
 	Series() ([]Series, error)
+	SeriesByID(seriesID int) (Series, error)
 	Episodes(seriesID int) ([]Episode, error)
 	EpisodeFile(episodeFileID int) (EpisodeFile, error)
 }
@@ -28,6 +32,32 @@ type Client struct {
 	APIKey string
 	Client http.Client
 	URL    string
+	// Logger, if not nil, is given the URL, status and latency of every
+	// request. The API key is sent in a header rather than the URL, and any
+	// password in the URL is redacted
+	Logger *slog.Logger
+}
+
+// do sends req to Sonarr, logging it if the client has a logger.
+func (c Client) do(req *http.Request) (*http.Response, error) {
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	// The API key is sent in a header, but the URL may hold a password
+	u := req.URL.Redacted()
+	logger.Debug("sonarr request", "method", req.Method, "url", u)
+
+	start := time.Now()
+	res, err := c.Client.Do(req)
+	if err != nil {
+		logger.Info("sonarr request failed", "method", req.Method, "url", u, "latency", time.Since(start), "error", err)
+		return nil, err
+	}
+
+	logger.Info("sonarr response", "method", req.Method, "url", u, "status", res.StatusCode, "latency", time.Since(start))
+	return res, nil
 }
 
 func (c Client) Series() ([]Series, error) {
@@ -45,7 +75,7 @@ func (c Client) Series() ([]Series, error) {
 		return series, err
 	}
 
-	res, err := c.Client.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return series, err
 	}
@@ -66,6 +96,47 @@ func (c Client) Series() ([]Series, error) {
 	return series, nil
 }
 
+func (c Client) SeriesByID(seriesID int) (Series, error) {
+	var series Series
+
+	req, err := sling.
+		New().
+		Get(c.URL).
+		Path(APIEndpoint).
+		Path(SeriesEndpoint).
+		Path(strconv.Itoa(seriesID)).
+		Set("X-Api-Key", c.APIKey).
+		Request()
+
+	if err != nil {
+		return series, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return series, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return series, ErrUnauthorized
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return series, ErrNotFound
+	}
+
+	bytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return series, err
+	}
+
+	if err := json.Unmarshal(bytes, &series); err != nil {
+		return series, err
+	}
+
+	return series, nil
+}
+
 func (c Client) Episodes(seriesID int) ([]Episode, error) {
 	var episodes []Episode
 
@@ -84,7 +155,7 @@ func (c Client) Episodes(seriesID int) ([]Episode, error) {
 		return episodes, err
 	}
 
-	res, err := c.Client.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return episodes, err
 	}
@@ -121,7 +192,7 @@ func (c Client) EpisodeFile(episodeFileID int) (EpisodeFile, error) {
 		return episodeFile, err
 	}
 
-	res, err := c.Client.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return episodeFile, err
 	}
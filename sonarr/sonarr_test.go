@@ -1,8 +1,11 @@
 package sonarr_test
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	. "github.com/lgug2z/sgrab/sonarr"
 
@@ -40,6 +43,33 @@ var _ = Describe("Sonarr", func() {
 		})
 	})
 
+	Describe("When looking up a series by its ID", func() {
+		It("Returns the Series object with that ID", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", fmt.Sprintf("/api/series/1")),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series[0]),
+				),
+			)
+
+			series, err := sonarr.SeriesByID(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(series).To(Equal(Series{Title: "Westworld", ID: 1}))
+		})
+
+		It("Returns an error if there is no series with that ID", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", fmt.Sprintf("/api/series/2")),
+					ghttp.RespondWith(http.StatusNotFound, ""),
+				),
+			)
+
+			_, err := sonarr.SeriesByID(2)
+			Expect(err).To(Equal(ErrNotFound))
+		})
+	})
+
 	Describe("When looking up episodes of a valid series on the server", func() {
 		It("Returns a list of Episode objects for that series", func() {
 			server.AppendHandlers(
@@ -71,6 +101,30 @@ var _ = Describe("Sonarr", func() {
 		})
 	})
 
+	Describe("When making a request with a logger", func() {
+		It("Logs the URL and status without the password", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", fmt.Sprintf("/api/series/")),
+					ghttp.VerifyBasicAuth("me", "secretpass"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, series),
+				),
+			)
+
+			var logs bytes.Buffer
+			sonarr.URL = strings.Replace(server.URL(), "http://", "http://me:secretpass@", 1)
+			sonarr.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+			defer func() { sonarr.Logger = nil }()
+
+			_, err := sonarr.Series()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs.String()).To(ContainSubstring("/api/series/"))
+			Expect(logs.String()).To(ContainSubstring("status=200"))
+			Expect(logs.String()).To(ContainSubstring("me:xxxxx@"))
+			Expect(logs.String()).ToNot(ContainSubstring("secretpass"))
+		})
+	})
+
 	Describe("When making a request with an invalid API key", func() {
 		It("An error is returned", func() {
 			server.AppendHandlers(